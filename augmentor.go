@@ -0,0 +1,108 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"context"
+	"log/slog"
+
+	"cloud.google.com/go/logging"
+	"github.com/pkg/errors"
+	spb "google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog/internal/options"
+)
+
+// EntryAugmentor augments a logging.Entry before it is logged, e.g. with a
+// tenant id, feature flags or build information.  The current context and
+// group path are provided, in case they are needed by the augmentor.  An
+// error is returned if the entry could not be augmented.
+type EntryAugmentor func(ctx context.Context, e *logging.Entry, groups []string) error
+
+// WithEntryAugmentor returns an option that applies the augmentor to each
+// entry, after the augmentors of the other options.  Augmentors are applied in
+// the order they were added.
+//
+// The entry's payload is a copy of which only the root struct, and the structs
+// of the groups opened via WithGroup, are private to the entry; the other
+// nested structs, e.g. of the groups bound via WithAttrs, are shared with the
+// handler and the entries already logged.  They MUST be replaced rather than
+// modified, e.g. using the struct returned by MutablePayload.
+//
+// An error returned by the augmentor, or a panic, is reported to the handler's
+// ErrorHandler, see WithOnError, identifying the augmentor by name.  The
+// entry is logged regardless.
+func WithEntryAugmentor(name string, augmentor EntryAugmentor) options.OptionProcessor {
+	if augmentor == nil {
+		panic("entry augmentor is nil")
+	}
+
+	return func(o *options.Options) {
+		o.NamedEntryAugmentors = append(o.NamedEntryAugmentors, options.NamedEntryAugmentor{
+			Name:    name,
+			Augment: augmentor,
+		})
+	}
+}
+
+// augment applies the named augmentors to the entry, reporting their
+// failures.
+func (h *GcpHandler) augment(ctx context.Context, record slog.Record, entry *logging.Entry) {
+	for _, a := range h.namedAugmentors {
+		if err := safeAugment(ctx, a, entry, h.groups); err != nil {
+			h.onError(errors.Wrapf(err, "entry augmentor %q failed", a.Name), record, *entry)
+		}
+	}
+}
+
+// MutablePayload returns the struct of the entry's payload at the path of
+// groups, which an EntryAugmentor may modify without affecting other
+// entries.  The structs along the path are copied, sharing their values, and
+// created if missing.  Nil is returned if the payload is not a struct, as it
+// is for the entries built by a GcpHandler, or if a field along the path is
+// not a struct.
+func MutablePayload(e *logging.Entry, path ...string) *spb.Struct {
+	current, ok := e.Payload.(*spb.Struct)
+	if !ok || current == nil {
+		return nil
+	}
+
+	if current.Fields == nil {
+		current.Fields = make(map[string]*spb.Value)
+	}
+
+	for _, k := range path {
+		v, found := current.GetFields()[k]
+		if found && v.GetStructValue() == nil {
+			return nil
+		}
+
+		s := shallowCopy(v.GetStructValue())
+		current.Fields[k] = &spb.Value{Kind: &spb.Value_StructValue{StructValue: s}}
+		current = s
+	}
+
+	return current
+}
+
+func safeAugment(ctx context.Context, a options.NamedEntryAugmentor, entry *logging.Entry, groups []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("panic: %v", r)
+		}
+	}()
+
+	return a.Augment(ctx, entry, groups)
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"cloud.google.com/go/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog"
+)
+
+type tenantKey struct{}
+
+func addTenant(ctx context.Context, e *logging.Entry, _ []string) error {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	if !ok {
+		return errors.New("no tenant")
+	}
+
+	if e.Labels == nil {
+		e.Labels = make(map[string]string)
+	}
+
+	e.Labels["tenant"] = tenant
+
+	return nil
+}
+
+func TestWithEntryAugmentor(t *testing.T) {
+	c := &collector{}
+
+	var errs []error
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithEntryAugmentor("tenant", addTenant),
+		gslog.WithEntryAugmentor("build", func(_ context.Context, e *logging.Entry, _ []string) error {
+			e.Labels["build"] = "1.2.3"
+			return nil
+		}),
+		gslog.WithOnError(func(err error, _ slog.Record, _ logging.Entry) {
+			errs = append(errs, err)
+		})))
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	ctx = gslog.WithLabels(ctx, gslog.Label("a", "one"))
+
+	l.InfoContext(ctx, "How now brown cow?")
+
+	assert.Len(t, c.entries, 1)
+	assert.Equal(t, map[string]string{"a": "one", "tenant": "acme", "build": "1.2.3"}, c.entries[0].Labels)
+	assert.Empty(t, errs)
+}
+
+func TestWithEntryAugmentor_failures(t *testing.T) {
+	c := &collector{}
+
+	var errs []string
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithEntryAugmentor("tenant", addTenant),
+		gslog.WithEntryAugmentor("build", func(_ context.Context, e *logging.Entry, _ []string) error {
+			e.Labels["build"] = "1.2.3"
+			return nil
+		}),
+		gslog.WithOnError(func(err error, r slog.Record, _ logging.Entry) {
+			errs = append(errs, r.Message+": "+err.Error())
+		})))
+
+	l.Info("How now brown cow?")
+
+	// the entry is logged regardless
+	assert.Len(t, c.entries, 1)
+	assert.Equal(t, []string{
+		`How now brown cow?: entry augmentor "tenant" failed: no tenant`,
+		`How now brown cow?: entry augmentor "build" failed: panic: assignment to entry in nil map`,
+	}, errs)
+}
+
+func TestWithEntryAugmentor_nil(t *testing.T) {
+	assert.Panics(t, func() {
+		gslog.WithEntryAugmentor("nil", nil)
+	})
+}
+
+func TestMutablePayload(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithEntryAugmentor("tenant", func(ctx context.Context, e *logging.Entry, _ []string) error {
+			req := gslog.MutablePayload(e, "req")
+			req.Fields["tenant"] = structpb.NewStringValue(ctx.Value(tenantKey{}).(string))
+
+			return nil
+		})))
+	l = l.With(slog.Group("req", "id", 1))
+
+	l.InfoContext(context.WithValue(context.Background(), tenantKey{}, "t-one"), "one")
+	l.InfoContext(context.WithValue(context.Background(), tenantKey{}, "t-two"), "two")
+
+	tenant := func(e logging.Entry) string {
+		return e.Payload.(*structpb.Struct).GetFields()["req"].GetStructValue().GetFields()["tenant"].GetStringValue()
+	}
+
+	// the earlier entry is left unchanged
+	assert.Equal(t, "t-one", tenant(c.entries[0]))
+	assert.Equal(t, "t-two", tenant(c.entries[1]))
+}
+
+func TestMutablePayload_notStruct(t *testing.T) {
+	e := &logging.Entry{Payload: "How now brown cow?"}
+	assert.Nil(t, gslog.MutablePayload(e))
+
+	e = &logging.Entry{Payload: &structpb.Struct{Fields: map[string]*structpb.Value{"a": structpb.NewNumberValue(1)}}}
+	assert.Nil(t, gslog.MutablePayload(e, "a"))
+	assert.NotNil(t, gslog.MutablePayload(e, "b", "c"))
+	assert.Contains(t, e.Payload.(*structpb.Struct).GetFields()["b"].GetStructValue().GetFields(), "c")
+}
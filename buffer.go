@@ -0,0 +1,145 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"cloud.google.com/go/logging"
+
+	"m4o.io/gslog/internal/options"
+)
+
+// DefaultBufferSize is the number of entries held by the buffers attached to
+// contexts via WithBuffer, unless configured otherwise via WithBuffering.
+const DefaultBufferSize = 256
+
+type bufferKey struct{}
+
+// buffer holds, in a ring, the most recent entries of the records logged
+// using a context below the handler's level.
+type buffer struct {
+	mu      sync.Mutex
+	entries []bufferedEntry
+	next    int
+	ended   atomic.Bool
+}
+
+type bufferedEntry struct {
+	logger    Logger
+	record    slog.Record
+	entry     logging.Entry
+	operation *operation
+}
+
+// WithBuffer returns a new Context with a buffer attached, typically at the
+// start of the handling of a request.  The records logged using that context
+// at a level lower than the handler's, e.g. slog.LevelDebug, are not
+// discarded but kept in the buffer.  When a record is logged at, or above,
+// the trigger level set via WithBuffering, slog.LevelError by default, the
+// buffered entries are logged first, in order and with their original
+// timestamps and trace information.
+//
+// Only the most recent entries are kept, see WithBuffering.  Use EndBuffer
+// to discard the buffered entries once the request ends cleanly.
+func WithBuffer(ctx context.Context) context.Context {
+	//nolint:exhaustruct
+	return context.WithValue(ctx, bufferKey{}, &buffer{})
+}
+
+// EndBuffer discards the entries held by the buffer attached to the context
+// via WithBuffer, if any.  The records subsequently logged using the context
+// below the handler's level are discarded rather than buffered.
+func EndBuffer(ctx context.Context) {
+	b := bufferFrom(ctx)
+	if b == nil {
+		return
+	}
+
+	b.ended.Store(true)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries = nil
+	b.next = 0
+}
+
+// WithBuffering returns an option that configures the buffers attached to
+// contexts via WithBuffer: the number of most recent entries they hold and
+// the level at, or above, which they are logged.  A size of zero, or less,
+// uses DefaultBufferSize.
+func WithBuffering(size int, trigger slog.Level) options.OptionProcessor {
+	return func(o *options.Options) {
+		o.BufferSize = size
+		o.BufferTrigger = trigger
+	}
+}
+
+func bufferFrom(ctx context.Context) *buffer {
+	if ctx == nil {
+		return nil
+	}
+
+	b, _ := ctx.Value(bufferKey{}).(*buffer)
+
+	return b
+}
+
+// buffering reports whether the context has a buffer that still accepts
+// entries.
+func buffering(ctx context.Context) bool {
+	b := bufferFrom(ctx)
+
+	return b != nil && !b.ended.Load()
+}
+
+// add keeps the entry, replacing the oldest one once the buffer holds size
+// entries.
+func (b *buffer) add(size int, e bufferedEntry) {
+	if b.ended.Load() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.entries) < size {
+		b.entries = append(b.entries, e)
+
+		return
+	}
+
+	b.entries[b.next] = e
+	b.next = (b.next + 1) % size
+}
+
+// drain returns the buffered entries, oldest first, and empties the buffer.
+func (b *buffer) drain() []bufferedEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := make([]bufferedEntry, 0, len(b.entries))
+	entries = append(entries, b.entries[b.next:]...)
+	entries = append(entries, b.entries[:b.next]...)
+
+	b.entries = nil
+	b.next = 0
+
+	return entries
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog"
+	"m4o.io/gslog/otel"
+)
+
+func messages(entries []logging.Entry) []string {
+	var m []string
+	for _, e := range entries {
+		m = append(m, e.Payload.(*structpb.Struct).GetFields()[gslog.MessageKey].GetStringValue())
+	}
+
+	return m
+}
+
+func TestWithBuffer_trigger(t *testing.T) {
+	c := &collector{}
+	h := gslog.NewGcpHandler(c, otel.WithOtelTracing("my-project"))
+
+	traceID, _ := trace.TraceIDFromHex("52fc1643a9381fc674742bb0067101e7")
+	spanID, _ := trace.SpanIDFromHex("d3e9e8c51cb190df")
+
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = gslog.WithBuffer(ctx)
+
+	assert.True(t, h.Enabled(ctx, slog.LevelDebug))
+	assert.False(t, h.Enabled(context.Background(), slog.LevelDebug))
+
+	for i, msg := range []string{"one", "two"} {
+		r := slog.NewRecord(testTime.Add(time.Duration(i)*time.Second), slog.LevelDebug, msg, 0)
+		assert.NoError(t, h.Handle(ctx, r))
+	}
+
+	assert.NoError(t, h.Handle(ctx, slog.NewRecord(testTime, slog.LevelInfo, "info", 0)))
+	assert.Equal(t, []string{"info"}, messages(c.entries))
+
+	assert.NoError(t, h.Handle(ctx, slog.NewRecord(testTime, slog.LevelError, "Ouch!", 0)))
+	assert.Equal(t, []string{"info", "one", "two", "Ouch!"}, messages(c.entries))
+
+	assert.Equal(t, testTime, c.entries[1].Timestamp)
+	assert.Equal(t, testTime.Add(time.Second), c.entries[2].Timestamp)
+	assert.Equal(t, logging.Debug, c.entries[1].Severity)
+	assert.Equal(t, "projects/my-project/traces/52fc1643a9381fc674742bb0067101e7", c.entries[1].Trace)
+
+	// the buffer was emptied by the trigger
+	assert.NoError(t, h.Handle(ctx, slog.NewRecord(testTime, slog.LevelError, "Ouch!", 0)))
+	assert.Equal(t, []string{"info", "one", "two", "Ouch!", "Ouch!"}, messages(c.entries))
+}
+
+func TestWithBuffer_ring(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithBuffering(2, slog.LevelWarn)))
+
+	ctx := gslog.WithBuffer(context.Background())
+
+	l.DebugContext(ctx, "one")
+	l.DebugContext(ctx, "two")
+	l.DebugContext(ctx, "three")
+	l.WarnContext(ctx, "Danger!")
+
+	assert.Equal(t, []string{"two", "three", "Danger!"}, messages(c.entries))
+}
+
+func TestEndBuffer(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c))
+
+	ctx := gslog.WithBuffer(context.Background())
+
+	l.DebugContext(ctx, "one")
+	gslog.EndBuffer(ctx)
+	l.DebugContext(ctx, "two")
+	l.ErrorContext(ctx, "Ouch!")
+
+	assert.Equal(t, []string{"Ouch!"}, messages(c.entries))
+
+	// no buffer attached is a no-op
+	gslog.EndBuffer(context.Background())
+}
+
+func TestWithBuffer_operationFirst(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c))
+
+	// a discarded buffered entry does not take the operation's first entry
+	ctx := gslog.WithBuffer(gslog.WithOperation(context.Background(), "op-1", "test"))
+	l.DebugContext(ctx, "discarded")
+	gslog.EndBuffer(ctx)
+	l.InfoContext(ctx, "one")
+	l.InfoContext(ctx, "two")
+
+	assert.Equal(t, []string{"one", "two"}, messages(c.entries))
+	assert.True(t, c.entries[0].Operation.GetFirst())
+	assert.False(t, c.entries[1].Operation.GetFirst())
+
+	// a drained buffered entry is the operation's first entry
+	c.entries = nil
+	ctx = gslog.WithBuffer(gslog.WithOperation(context.Background(), "op-2", "test"))
+	l.DebugContext(ctx, "buffered")
+	l.ErrorContext(ctx, "Ouch!")
+
+	assert.Equal(t, []string{"buffered", "Ouch!"}, messages(c.entries))
+	assert.True(t, c.entries[0].Operation.GetFirst())
+	assert.False(t, c.entries[1].Operation.GetFirst())
+}
+
+type failingSync struct {
+	calls int
+	err   error
+}
+
+func (f *failingSync) Log(logging.Entry) {}
+
+func (f *failingSync) LogSync(context.Context, logging.Entry) error {
+	f.calls++
+
+	return f.err
+}
+
+func (f *failingSync) Flush() error { return nil }
+
+func TestWithBuffer_drainErrors(t *testing.T) {
+	ouch := errors.New("ouch")
+	f := &failingSync{err: ouch}
+	var reported []string
+
+	h := gslog.NewGcpHandler(f,
+		gslog.WithOnError(func(_ error, r slog.Record, _ logging.Entry) {
+			reported = append(reported, r.Message)
+		}),
+		gslog.WithErrorsReturned())
+
+	ctx := gslog.WithSync(gslog.WithBuffer(context.Background()), true)
+
+	for _, msg := range []string{"one", "two"} {
+		assert.NoError(t, h.Handle(ctx, slog.NewRecord(testTime, slog.LevelDebug, msg, 0)))
+	}
+
+	assert.Equal(t, 0, f.calls)
+
+	// all the buffered entries, and the trigger's, are logged despite errors
+	err := h.Handle(ctx, slog.NewRecord(testTime, slog.LevelError, "Ouch!", 0))
+	assert.ErrorIs(t, err, ouch)
+	assert.Equal(t, 3, f.calls)
+	assert.Equal(t, []string{"one", "two", "Ouch!"}, reported)
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"m4o.io/gslog/internal/options"
+)
+
+// WithCallerLevels returns an option that overrides the handler's level for
+// the records logged from the functions matching the supplied prefixes.  A
+// prefix is either a package path, e.g. "github.com/ourorg/payments", which
+// matches the functions of that package and of the packages below it, or a
+// fully qualified function name, e.g. "github.com/ourorg/payments.Charge".
+// A trailing "/..." is ignored.  The longest matching prefix wins; records
+// logged from functions matching none use the handler's level.
+//
+// The logging function is resolved from the record's PC, the resolution being
+// cached.  Records without a PC, e.g. passed directly to the handler's Handle
+// method, are not filtered by their level.
+func WithCallerLevels(levels map[string]slog.Level) options.OptionProcessor {
+	return func(o *options.Options) {
+		o.CallerLevels = levels
+	}
+}
+
+// callerLeveler resolves the level of records from the function that logged
+// them.
+type callerLeveler struct {
+	rules []callerRule
+	min   slog.Level
+
+	// cache maps PCs to the index of the matching rule, or -1.
+	cache sync.Map
+}
+
+type callerRule struct {
+	prefix string
+	level  slog.Level
+}
+
+func newCallerLeveler(levels map[string]slog.Level) *callerLeveler {
+	//nolint:exhaustruct
+	l := &callerLeveler{}
+
+	for prefix, level := range levels {
+		prefix = strings.TrimSuffix(prefix, "/...")
+
+		if len(l.rules) == 0 || level < l.min {
+			l.min = level
+		}
+
+		l.rules = append(l.rules, callerRule{prefix: prefix, level: level})
+	}
+
+	// longest prefixes first, so that the first matching rule wins
+	sort.Slice(l.rules, func(i, j int) bool {
+		return len(l.rules[i].prefix) > len(l.rules[j].prefix)
+	})
+
+	return l
+}
+
+// level returns the level of the rule matching the function at pc, if any.
+func (l *callerLeveler) level(pc uintptr) (slog.Level, bool) {
+	if pc == 0 {
+		return 0, false
+	}
+
+	i, ok := l.cache.Load(pc)
+	if !ok {
+		i = l.match(pc)
+		l.cache.Store(pc, i)
+	}
+
+	idx, _ := i.(int)
+	if idx < 0 {
+		return 0, false
+	}
+
+	return l.rules[idx].level, true
+}
+
+func (l *callerLeveler) match(pc uintptr) int {
+	function := frameOf(pc).Function
+
+	for i, r := range l.rules {
+		if matchesCaller(function, r.prefix) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// matchesCaller reports whether the fully qualified function name starts with
+// the prefix, on a package path or function name boundary.
+func matchesCaller(function, prefix string) bool {
+	if !strings.HasPrefix(function, prefix) {
+		return false
+	}
+
+	if len(function) == len(prefix) {
+		return true
+	}
+
+	switch function[len(prefix)] {
+	case '/', '.':
+		return true
+	default:
+		return false
+	}
+}
+
+// minLevel returns the level below which the record is not logged, i.e. the
+// level attached to the context, the level resolved from the function that
+// logged it or the handler's level.
+func (h *GcpHandler) minLevel(ctx context.Context, record slog.Record) slog.Level {
+	if level, ok := levelFrom(ctx); ok {
+		return level
+	}
+
+	if h.callerLeveler != nil {
+		if level, ok := h.callerLeveler.level(record.PC); ok {
+			return level
+		}
+	}
+
+	return h.level.Level()
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"m4o.io/gslog"
+)
+
+func logFromPayments(l *slog.Logger, msg string) {
+	l.Debug(msg)
+}
+
+func logFromPaymentsRefund(l *slog.Logger, msg string) {
+	l.Debug(msg)
+}
+
+func logFromElsewhere(l *slog.Logger, msg string) {
+	l.Info(msg)
+}
+
+func TestWithCallerLevels(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithLogLeveler(slog.LevelWarn),
+		gslog.WithCallerLevels(map[string]slog.Level{
+			"m4o.io/gslog_test.logFromPayments": slog.LevelDebug,
+		})))
+
+	logFromPayments(l, "one")
+	logFromPaymentsRefund(l, "two")
+	logFromElsewhere(l, "three")
+	l.Warn("four")
+
+	assert.Equal(t, []string{"one", "four"}, messages(c.entries))
+}
+
+func TestWithCallerLevels_longestPrefix(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithLogLeveler(slog.LevelDebug),
+		gslog.WithCallerLevels(map[string]slog.Level{
+			"m4o.io/gslog_test/...":             slog.LevelError,
+			"m4o.io/gslog_test.logFromPayments": slog.LevelDebug,
+			"m4o.io/gslog_tes":                  slog.LevelDebug,
+		})))
+
+	for i := 0; i < 2; i++ {
+		logFromPayments(l, "one")
+		logFromPaymentsRefund(l, "two")
+		logFromElsewhere(l, "three")
+		l.Error("four")
+	}
+
+	assert.Equal(t, []string{"one", "four", "one", "four"}, messages(c.entries))
+}
+
+func TestWithCallerLevels_buffered(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithLogLeveler(slog.LevelDebug),
+		gslog.WithCallerLevels(map[string]slog.Level{
+			"m4o.io/gslog_test": slog.LevelWarn,
+		})))
+
+	ctx := gslog.WithBuffer(context.Background())
+
+	l.InfoContext(ctx, "one")
+	assert.Empty(t, c.entries)
+
+	l.ErrorContext(ctx, "Ouch!")
+	assert.Equal(t, []string{"one", "Ouch!"}, messages(c.entries))
+}
+
+func BenchmarkGcpHandler_Handle_callerLevels(b *testing.B) {
+	l := slog.New(gslog.NewGcpHandler(&collector{},
+		gslog.WithLogLeveler(slog.LevelWarn),
+		gslog.WithCallerLevels(map[string]slog.Level{
+			"github.com/ourorg/payments/...": slog.LevelDebug,
+			"github.com/ourorg/orders":       slog.LevelInfo,
+		})))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l.Debug("How now brown cow?")
+	}
+}
+
+func TestWithCallerLevels_levelController(t *testing.T) {
+	lc := gslog.NewLevelController(slog.LevelError)
+
+	c := &syncCollector{}
+	lc.SetLogger(slog.New(gslog.NewGcpHandler(c,
+		gslog.WithLogLeveler(lc),
+		gslog.WithCallerLevels(map[string]slog.Level{
+			"m4o.io/gslog_test.logFromPayments": slog.LevelDebug,
+		}))))
+
+	// the change is logged regardless of the levels
+	lc.Set(slog.LevelError, 0)
+
+	assert.Equal(t, 1, c.len())
+	assert.Equal(t, "Log level changed.", messages(c.entries)[0])
+}
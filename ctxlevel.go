@@ -0,0 +1,135 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// DebugHeader is the conventional header used to request that a single
+// request be logged at a lower level, see LevelFromHeader.
+const DebugHeader = "X-Debug-Log"
+
+type levelKey struct{}
+
+// WithLevel returns a new Context with a level that overrides the handler's
+// level, and the levels set via WithCallerLevels, for the records logged
+// using that context.  This allows, for instance, debug records to be logged
+// for a single request, tenant or trace.
+func WithLevel(ctx context.Context, level slog.Level) context.Context {
+	return context.WithValue(ctx, levelKey{}, level)
+}
+
+func levelFrom(ctx context.Context) (slog.Level, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+
+	level, ok := ctx.Value(levelKey{}).(slog.Level)
+
+	return level, ok
+}
+
+// LevelFromHeader returns an http.Handler that attaches a level to the
+// request's context, see WithLevel, when the request carries the header, e.g.
+// DebugHeader, before calling next.  A boolean header value, e.g. "true",
+// attaches the supplied level; any other value is parsed using ParseLevel.
+// Invalid values and false are ignored.
+//
+// The header MUST only be accepted from trusted sources, e.g. by stripping it
+// from requests at the edge of the network.
+func LevelFromHeader(next http.Handler, header string, level slog.Level) http.Handler {
+	if next == nil {
+		panic("handler is nil")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lvl, ok := levelFromValue(r.Header.Get(header), level); ok {
+			r = r.WithContext(WithLevel(r.Context(), lvl))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LevelUnaryServerInterceptor returns a grpc.UnaryServerInterceptor that
+// attaches a level to the call's context when the incoming metadata carries
+// the key, with the same semantics as LevelFromHeader.
+func LevelUnaryServerInterceptor(key string, level slog.Level) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(levelFromMetadata(ctx, key, level), req)
+	}
+}
+
+// LevelStreamServerInterceptor returns a grpc.StreamServerInterceptor that
+// attaches a level to the stream's context when the incoming metadata carries
+// the key, with the same semantics as LevelFromHeader.
+func LevelStreamServerInterceptor(key string, level slog.Level) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := levelFromMetadata(ss.Context(), key, level)
+		if ctx == ss.Context() {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &levelServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type levelServerStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx
+}
+
+func (s *levelServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func levelFromMetadata(ctx context.Context, key string, level slog.Level) context.Context {
+	values := metadata.ValueFromIncomingContext(ctx, strings.ToLower(key))
+	if len(values) == 0 {
+		return ctx
+	}
+
+	if lvl, ok := levelFromValue(values[0], level); ok {
+		return WithLevel(ctx, lvl)
+	}
+
+	return ctx
+}
+
+// levelFromValue returns the level requested by a header value.
+func levelFromValue(value string, level slog.Level) (slog.Level, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if b, err := strconv.ParseBool(value); err == nil {
+		return level, b
+	}
+
+	lvl, err := ParseLevel(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return lvl, true
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"m4o.io/gslog"
+)
+
+func TestWithLevel(t *testing.T) {
+	c := &collector{}
+	h := gslog.NewGcpHandler(c, gslog.WithLogLeveler(slog.LevelWarn))
+	l := slog.New(h)
+
+	debug := gslog.WithLevel(context.Background(), slog.LevelDebug)
+	quiet := gslog.WithLevel(context.Background(), slog.LevelError)
+
+	assert.True(t, h.Enabled(debug, slog.LevelDebug))
+	assert.False(t, h.Enabled(context.Background(), slog.LevelDebug))
+	assert.False(t, h.Enabled(quiet, slog.LevelWarn))
+
+	l.DebugContext(debug, "one")
+	l.Debug("two")
+	l.WarnContext(quiet, "three")
+	l.Warn("four")
+
+	assert.Equal(t, []string{"one", "four"}, messages(c.entries))
+}
+
+func TestWithLevel_callerLevels(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithCallerLevels(map[string]slog.Level{
+			"m4o.io/gslog_test": slog.LevelError,
+		})))
+
+	l.InfoContext(gslog.WithLevel(context.Background(), slog.LevelDebug), "one")
+	l.Info("two")
+
+	assert.Equal(t, []string{"one"}, messages(c.entries))
+}
+
+func levelOf(ctx context.Context) string {
+	h := gslog.NewGcpHandler(&collector{}, gslog.WithLogLeveler(gslog.LevelEmergency))
+
+	for _, lvl := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+		if h.Enabled(ctx, lvl) {
+			return lvl.String()
+		}
+	}
+
+	return ""
+}
+
+func TestLevelFromHeader(t *testing.T) {
+	var got string
+	h := gslog.LevelFromHeader(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = levelOf(r.Context())
+	}), gslog.DebugHeader, slog.LevelDebug)
+
+	tests := map[string]string{
+		"true":  "DEBUG",
+		"1":     "DEBUG",
+		"WARN":  "WARN",
+		"false": "",
+		"LOUD":  "",
+		"":      "",
+	}
+
+	for value, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if value != "" {
+			req.Header.Set(gslog.DebugHeader, value)
+		}
+
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, want, got, value)
+	}
+
+	assert.Panics(t, func() {
+		gslog.LevelFromHeader(nil, gslog.DebugHeader, slog.LevelDebug)
+	})
+}
+
+type stubServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stubServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestLevelServerInterceptors(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(gslog.DebugHeader, "true"))
+
+	unary := gslog.LevelUnaryServerInterceptor(gslog.DebugHeader, slog.LevelDebug)
+
+	var got string
+	_, err := unary(ctx, nil, nil, func(ctx context.Context, _ any) (any, error) {
+		got = levelOf(ctx)
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG", got)
+
+	_, err = unary(context.Background(), nil, nil, func(ctx context.Context, _ any) (any, error) {
+		got = levelOf(ctx)
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "", got)
+
+	stream := gslog.LevelStreamServerInterceptor(gslog.DebugHeader, slog.LevelDebug)
+
+	err = stream(nil, &stubServerStream{ctx: ctx}, nil, func(_ any, ss grpc.ServerStream) error {
+		got = levelOf(ss.Context())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG", got)
+}
@@ -0,0 +1,273 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"google.golang.org/protobuf/proto"
+	spb "google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog/internal/attr"
+	"m4o.io/gslog/internal/options"
+)
+
+const (
+	// RepeatCountKey is the key of the field holding the number of identical
+	// records collapsed into a deduplicated entry.
+	RepeatCountKey = "repeat_count"
+
+	// FirstSeenKey is the key of the field holding the time of the first
+	// record collapsed into a deduplicated entry.
+	FirstSeenKey = "first_seen"
+
+	// LastSeenKey is the key of the field holding the time of the last
+	// record collapsed into a deduplicated entry.
+	LastSeenKey = "last_seen"
+
+	// maxBursts is the maximum number of distinct records tracked within a
+	// window.  The records beyond it are logged without being deduplicated.
+	maxBursts = 10_000
+)
+
+// WithDeduplication returns an option that collapses bursts of identical
+// records, i.e. with the same level, message and attributes, routed to the
+// same Logger and logged within window of the first one.
+//
+// The first record of a burst is logged as usual.  The identical records that
+// follow it within the window are suppressed and, when the window closes or
+// the handler is flushed, collapsed into a single entry, a copy of the first
+// one that carries RepeatCountKey, FirstSeenKey and LastSeenKey fields
+// describing the suppressed records.  Summaries are size limited, see
+// WithSizeLimit, and logged synchronously or not, see WithSyncPolicy, as the
+// other entries are.
+//
+// Only the fingerprints of the records are kept until a duplicate is logged,
+// and at most 10,000 distinct records are tracked within a window; records
+// beyond that are logged as usual.
+func WithDeduplication(window time.Duration) options.OptionProcessor {
+	if window <= 0 {
+		panic("deduplication window must be positive")
+	}
+
+	return func(o *options.Options) {
+		o.DedupWindow = window
+	}
+}
+
+// deduplicator tracks the bursts of identical entries.  A single timer closes
+// the bursts as their windows end.
+type deduplicator struct {
+	window   time.Duration
+	encoding *attr.Encoding
+
+	// summarize logs the summary of a burst, as the entries of the records
+	// are: size limited, synchronously or not, and reporting errors.
+	summarize func(logger Logger, record slog.Record, entry logging.Entry)
+
+	mu     sync.Mutex
+	bursts map[uint64]*burst
+	// order holds the keys of the bursts in the order they started, and so
+	// of the ends of their windows.
+	order []uint64
+	timer *time.Timer
+}
+
+// burst tracks the records identical to the first one logged within its
+// window.  The entry is only kept once a duplicate is logged.
+type burst struct {
+	ends      time.Time
+	record    slog.Record
+	entry     logging.Entry
+	logger    Logger
+	count     int
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+func newDeduplicator(
+	window time.Duration,
+	encoding *attr.Encoding,
+	summarize func(logger Logger, record slog.Record, entry logging.Entry),
+) *deduplicator {
+	//nolint:exhaustruct
+	return &deduplicator{
+		window:    window,
+		encoding:  encoding,
+		summarize: summarize,
+		bursts:    make(map[uint64]*burst),
+	}
+}
+
+// suppress reports whether the entry is a duplicate of one logged within the
+// window, in which case it is accounted for in the burst's summary.
+func (d *deduplicator) suppress(record slog.Record, e logging.Entry, logger Logger) bool {
+	key, ok := fingerprint(e, logger)
+	if !ok {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if b, found := d.bursts[key]; found {
+		if b.count == 0 {
+			b.record = record.Clone()
+			b.entry = e
+			b.logger = logger
+			b.firstSeen = e.Timestamp
+		}
+
+		b.count++
+		b.lastSeen = e.Timestamp
+
+		return true
+	}
+
+	if len(d.bursts) >= maxBursts {
+		return false
+	}
+
+	//nolint:exhaustruct
+	d.bursts[key] = &burst{ends: time.Now().Add(d.window)}
+	d.order = append(d.order, key)
+
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.window, d.sweep)
+	}
+
+	return false
+}
+
+// sweep closes the bursts whose window ended, logging the summaries of those
+// that suppressed records, and sets the timer for the next one to end.
+func (d *deduplicator) sweep() {
+	now := time.Now()
+
+	var ended []*burst
+
+	d.mu.Lock()
+
+	n := 0
+	for ; n < len(d.order); n++ {
+		b := d.bursts[d.order[n]]
+		if b.ends.After(now) {
+			break
+		}
+
+		delete(d.bursts, d.order[n])
+		ended = append(ended, b)
+	}
+
+	d.order = d.order[n:]
+
+	if len(d.order) > 0 {
+		d.timer = time.AfterFunc(d.bursts[d.order[0]].ends.Sub(now), d.sweep)
+	} else {
+		d.timer = nil
+	}
+
+	d.mu.Unlock()
+
+	for _, b := range ended {
+		d.emit(b)
+	}
+}
+
+// flush ends all the bursts, logging the summaries of those that suppressed
+// records.
+func (d *deduplicator) flush() {
+	d.mu.Lock()
+	bursts := d.bursts
+	d.bursts = make(map[uint64]*burst)
+	d.order = nil
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+
+	d.mu.Unlock()
+
+	for _, b := range bursts {
+		d.emit(b)
+	}
+}
+
+// emit logs the summary of the burst, if records were suppressed.
+func (d *deduplicator) emit(b *burst) {
+	if b.count == 0 {
+		return
+	}
+
+	e := b.entry
+
+	if payload, ok := e.Payload.(*spb.Struct); ok {
+		summary, _ := copyPath(payload, nil)
+		summary.Fields[RepeatCountKey] = attr.NewNumberValue(float64(b.count))
+		summary.Fields[FirstSeenKey] = d.encoding.NewTimeValue(b.firstSeen)
+		summary.Fields[LastSeenKey] = d.encoding.NewTimeValue(b.lastSeen)
+		e.Payload = summary
+	}
+
+	e.Timestamp = b.lastSeen
+	e.InsertID = ""
+
+	d.summarize(b.logger, b.record, e)
+}
+
+// fingerprint hashes the entry's severity and payload, and the identity of the
+// logger it is routed to.
+func fingerprint(e logging.Entry, logger Logger) (uint64, bool) {
+	payload, ok := e.Payload.(*spb.Struct)
+	if !ok {
+		return 0, false
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(payload)
+	if err != nil {
+		return 0, false
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(loggerID(logger)))
+	_, _ = h.Write([]byte(strconv.Itoa(int(e.Severity))))
+	_, _ = h.Write(b)
+
+	return h.Sum64(), true
+}
+
+// loggerID identifies the logger by its type and, for the kinds of values
+// that have one, e.g. pointers and funcs, its address.  Loggers are not
+// necessarily comparable, e.g. a LoggerFunc, and so cannot be map keys.
+func loggerID(logger Logger) string {
+	v := reflect.ValueOf(logger)
+
+	id := v.Type().String()
+
+	//nolint:exhaustive
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Func, reflect.Map, reflect.Chan, reflect.UnsafePointer:
+		id += "@" + strconv.FormatUint(uint64(v.Pointer()), 16)
+	}
+
+	return id
+}
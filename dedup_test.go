@@ -0,0 +1,172 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog"
+)
+
+type syncCollector struct {
+	mu sync.Mutex
+	collector
+}
+
+func (c *syncCollector) Log(e logging.Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.collector.Log(e)
+}
+
+func (c *syncCollector) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func TestDeduplication_flush(t *testing.T) {
+	c := &collector{}
+	h := gslog.NewGcpHandler(c, gslog.WithDeduplication(time.Hour))
+
+	for i := 0; i < 5; i++ {
+		r := slog.NewRecord(testTime.Add(time.Duration(i)*time.Second), slog.LevelError, "Ouch!", 0)
+		r.AddAttrs(slog.String("dependency", "db"))
+		assert.NoError(t, h.Handle(context.Background(), r))
+	}
+
+	r := slog.NewRecord(testTime, slog.LevelError, "Ouch!", 0)
+	r.AddAttrs(slog.String("dependency", "cache"))
+	assert.NoError(t, h.Handle(context.Background(), r))
+
+	assert.Len(t, c.entries, 2)
+
+	assert.NoError(t, h.Flush())
+	assert.Len(t, c.entries, 3)
+
+	summary := c.entries[2]
+	fields := summary.Payload.(*structpb.Struct).GetFields()
+
+	assert.Equal(t, logging.Error, summary.Severity)
+	assert.Equal(t, testTime.Add(4*time.Second), summary.Timestamp)
+	assert.Equal(t, "Ouch!", fields[gslog.MessageKey].GetStringValue())
+	assert.Equal(t, "db", fields["dependency"].GetStringValue())
+	assert.Equal(t, float64(4), fields[gslog.RepeatCountKey].GetNumberValue())
+	assert.Equal(t, "2000-01-02T03:04:06.000Z", fields[gslog.FirstSeenKey].GetStringValue())
+	assert.Equal(t, "2000-01-02T03:04:09.000Z", fields[gslog.LastSeenKey].GetStringValue())
+
+	// the first entry is left untouched
+	assert.NotContains(t, c.entries[0].Payload.(*structpb.Struct).GetFields(), gslog.RepeatCountKey)
+
+	// flushing again emits nothing more
+	assert.NoError(t, h.Flush())
+	assert.Len(t, c.entries, 3)
+}
+
+func TestDeduplication_window(t *testing.T) {
+	c := &syncCollector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithDeduplication(10*time.Millisecond)))
+
+	l.Error("Ouch!")
+	l.Error("Ouch!")
+	l.Error("Ouch!")
+
+	assert.Equal(t, 1, c.len())
+	assert.Eventually(t, func() bool { return c.len() == 2 }, time.Second, time.Millisecond)
+
+	// a new burst starts once the window has closed
+	l.Error("Ouch!")
+	assert.Equal(t, 3, c.len())
+}
+
+func TestWithDeduplication_invalid(t *testing.T) {
+	assert.Panics(t, func() {
+		gslog.WithDeduplication(0)
+	})
+}
+
+func TestDeduplication_sizeLimit(t *testing.T) {
+	const sizeLimit = 1024
+
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithDeduplication(time.Hour),
+		gslog.WithSizeLimit(sizeLimit, gslog.TruncateLargestStrings)))
+
+	for i := 0; i < 3; i++ {
+		l.Error("Ouch!", "body", strings.Repeat("x", 4*sizeLimit))
+	}
+
+	assert.NoError(t, l.Handler().(*gslog.GcpHandler).Flush())
+	assert.Len(t, c.entries, 2)
+
+	// the summary is size limited as the first entry is
+	for _, e := range c.entries {
+		assert.LessOrEqual(t, proto.Size(e.Payload.(*structpb.Struct)), sizeLimit)
+	}
+
+	fields := c.entries[1].Payload.(*structpb.Struct).GetFields()
+	assert.Equal(t, float64(2), fields[gslog.RepeatCountKey].GetNumberValue())
+}
+
+func TestDeduplication_routes(t *testing.T) {
+	c, audit := &collector{}, &collector{}
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithDeduplication(time.Hour),
+		gslog.WithRoute(gslog.RouteByLogName("audit"), audit)))
+
+	for i := 0; i < 2; i++ {
+		l.Error("Ouch!")
+		l.Error("Ouch!", gslog.LogNameKey, "audit")
+	}
+
+	// identical payloads routed to different loggers are different bursts
+	assert.Len(t, c.entries, 1)
+	assert.Len(t, audit.entries, 1)
+
+	assert.NoError(t, l.Handler().(*gslog.GcpHandler).Flush())
+	assert.Len(t, c.entries, 2)
+	assert.Len(t, audit.entries, 2)
+	assert.Equal(t, float64(1), audit.entries[1].Payload.(*structpb.Struct).GetFields()[gslog.RepeatCountKey].GetNumberValue())
+}
+
+func TestDeduplication_capacity(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithDeduplication(time.Hour)))
+
+	const tracked = 10_000
+
+	for i := 0; i < tracked; i++ {
+		l.Error("Ouch!", "request_id", i)
+	}
+
+	// records beyond the tracked ones are not deduplicated
+	l.Error("Ouch!", "request_id", tracked)
+	l.Error("Ouch!", "request_id", tracked)
+	assert.Len(t, c.entries, tracked+2)
+
+	l.Error("Ouch!", "request_id", 0)
+	assert.Len(t, c.entries, tracked+2)
+}
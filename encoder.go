@@ -0,0 +1,252 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"reflect"
+
+	spb "google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog/internal/attr"
+	"m4o.io/gslog/internal/options"
+)
+
+// DurationEncoding identifies how time.Duration attribute values are mapped,
+// see WithDurationEncoding.
+type DurationEncoding attr.DurationEncoding
+
+const (
+	// DurationNanos maps durations to their number of nanoseconds, the
+	// default.
+	DurationNanos = DurationEncoding(attr.DurationNanos)
+	// DurationSeconds maps durations to their number of seconds, as a
+	// floating point number, e.g. 1.5.
+	DurationSeconds = DurationEncoding(attr.DurationSeconds)
+	// DurationProto maps durations to strings following the Google
+	// convention for google.protobuf.Duration, e.g. "1.500s".
+	DurationProto = DurationEncoding(attr.DurationProto)
+	// DurationString maps durations to the strings returned by
+	// time.Duration's String method, e.g. "1.5s".
+	DurationString = DurationEncoding(attr.DurationString)
+)
+
+// WithDurationEncoding returns an option that specifies how time.Duration
+// attribute values are mapped, whether logged with the record, bound via
+// WithAttrs or nested in groups.
+func WithDurationEncoding(encoding DurationEncoding) options.OptionProcessor {
+	return func(o *options.Options) {
+		o.Encoding.Duration = attr.DurationEncoding(encoding)
+	}
+}
+
+// TimeEncoding identifies how time.Time attribute values are mapped, see
+// WithTimeEncoding.
+type TimeEncoding attr.TimeEncoding
+
+const (
+	// TimeRFC3339Millis maps times to RFC3339 strings with millisecond
+	// precision, the default.
+	TimeRFC3339Millis = TimeEncoding(attr.TimeRFC3339Millis)
+	// TimeRFC3339Micros maps times to RFC3339 strings with microsecond
+	// precision.
+	TimeRFC3339Micros = TimeEncoding(attr.TimeRFC3339Micros)
+	// TimeRFC3339Nanos maps times to RFC3339 strings with nanosecond
+	// precision.
+	TimeRFC3339Nanos = TimeEncoding(attr.TimeRFC3339Nanos)
+	// TimeUnixSeconds maps times to the number of seconds elapsed since the
+	// Unix epoch, with microsecond precision.
+	TimeUnixSeconds = TimeEncoding(attr.TimeUnixSeconds)
+	// TimeUnixMillis maps times to the number of milliseconds elapsed since
+	// the Unix epoch.
+	TimeUnixMillis = TimeEncoding(attr.TimeUnixMillis)
+)
+
+// WithTimeEncoding returns an option that specifies how time.Time attribute
+// values are mapped, whether logged with the record, bound via WithAttrs or
+// nested in groups.
+func WithTimeEncoding(encoding TimeEncoding) options.OptionProcessor {
+	return func(o *options.Options) {
+		o.Encoding.Time = attr.TimeEncoding(encoding)
+	}
+}
+
+// WithTimeInUTC returns an option that causes time.Time attribute values
+// mapped to RFC3339 strings to be converted to UTC rather than keeping their
+// original zone.
+func WithTimeInUTC() options.OptionProcessor {
+	return func(o *options.Options) {
+		o.Encoding.TimeUTC = true
+	}
+}
+
+// WithLosslessIntegers returns an option that causes integer attribute values
+// that cannot be exactly represented by a float64, i.e. beyond ±(2^53-1), to
+// be mapped to decimal strings rather than to numbers.  This includes the
+// integers nested in maps, slices and the values mapped via their JSON
+// representation.  Integers within the safe range are still mapped to
+// numbers.
+func WithLosslessIntegers() options.OptionProcessor {
+	return func(o *options.Options) {
+		o.Encoding.LosslessIntegers = true
+	}
+}
+
+// ErrorFields returns the attributes added to the structs error attribute
+// values are mapped to, see WithStructuredErrors, e.g. the code of a gRPC
+// status error.  It returns nil for the errors it does not know about.
+type ErrorFields attr.ErrorFields
+
+// WithStructuredErrors returns an option that causes error attribute values
+// that do not implement json.Marshaler to be mapped to structs rather than to
+// their Error() strings.  The structs hold:
+//
+//   - "message", the error's Error() string;
+//   - "type", the error's Go type, e.g. "*fs.PathError";
+//   - "chain", the errors successively returned by errors.Unwrap, each with
+//     their "message" and "type";
+//   - "errors", the errors joined by the error, e.g. by errors.Join, each
+//     mapped to a struct of its own;
+//   - "stack_trace", the frames of the stack trace carried by the innermost
+//     error of the chain implementing StackTrace(), as the errors of
+//     github.com/pkg/errors do.
+//
+// The fields returned by each ErrorFields are added to the structs, without
+// replacing the fields above.
+func WithStructuredErrors(fields ...ErrorFields) options.OptionProcessor {
+	for _, f := range fields {
+		if f == nil {
+			panic("error fields is nil")
+		}
+	}
+
+	return func(o *options.Options) {
+		o.Encoding.StructuredErrors = true
+
+		for _, f := range fields {
+			o.Encoding.ErrorFields = append(o.Encoding.ErrorFields, attr.ErrorFields(f))
+		}
+	}
+}
+
+// Conversion identifies an interface honored when mapping attribute values
+// that are neither registered with an encoder, see RegisterEncoder,
+// proto.Message values nor errors, see WithConversions.
+type Conversion attr.Conversion
+
+const (
+	// ConvertLogValuer maps slog.LogValuer values, e.g. nested in a
+	// slog.Group's slog.Any, to the values returned by their LogValue
+	// methods.
+	ConvertLogValuer = Conversion(attr.ConvertLogValuer)
+	// ConvertJSONMarshaler maps json.Marshaler values via their JSON
+	// representation.
+	ConvertJSONMarshaler = Conversion(attr.ConvertJSONMarshaler)
+	// ConvertTextMarshaler maps encoding.TextMarshaler values, e.g. net.IP,
+	// to the strings returned by their MarshalText methods, if they cannot
+	// otherwise be mapped, see WithConversions.
+	ConvertTextMarshaler = Conversion(attr.ConvertTextMarshaler)
+	// ConvertStringer maps fmt.Stringer values to the strings returned by
+	// their String methods, if they cannot otherwise be mapped, see
+	// WithConversions.
+	ConvertStringer = Conversion(attr.ConvertStringer)
+)
+
+// WithConversions returns an option that specifies the interfaces honored,
+// in order of precedence, when mapping attribute values that are neither
+// registered with an encoder, proto.Message values nor errors.  A value is
+// mapped using the first of ConvertLogValuer and ConvertJSONMarshaler it, or
+// a pointer to it, implements.  The values implementing neither are mapped as
+// is to a structpb.Value if possible, and via a JSON round-trip otherwise.
+//
+// ConvertTextMarshaler and ConvertStringer are only honored in place of the
+// JSON round-trip of a byte slice or array, or of a value whose JSON is an
+// empty object, e.g. a struct with unexported fields only, or when the
+// round-trip fails.  Structs with exported fields, e.g. url.URL, are still
+// mapped to JSON objects; register an encoder to map them otherwise.
+//
+// By default, all the conversions are honored, in the order of their
+// declaration.  Calling WithConversions without any conversion disables them
+// all.
+func WithConversions(conversions ...Conversion) options.OptionProcessor {
+	return func(o *options.Options) {
+		o.Encoding.Conversions = make([]attr.Conversion, 0, len(conversions))
+
+		for _, c := range conversions {
+			o.Encoding.Conversions = append(o.Encoding.Conversions, attr.Conversion(c))
+		}
+	}
+}
+
+// ProtoEncoding configures how proto.Message attribute values are mapped,
+// see WithProtoEncoding.  By default, proto.Message values are mapped as
+// protojson marshals them: using lowerCamelCase JSON field names, omitting
+// unpopulated fields and without their type.
+type ProtoEncoding struct {
+	// UseProtoNames causes the proto field names to be used rather than the
+	// lowerCamelCase JSON names.
+	UseProtoNames bool
+
+	// EmitUnpopulated causes unpopulated fields to be mapped with their
+	// default values.
+	EmitUnpopulated bool
+
+	// AddType causes an "@type" field holding the URL of the message's type
+	// to be added, as for a google.protobuf.Any.
+	AddType bool
+}
+
+// WithProtoEncoding returns an option that configures how proto.Message
+// attribute values are mapped to their structpb.Value equivalents.
+func WithProtoEncoding(encoding ProtoEncoding) options.OptionProcessor {
+	return func(o *options.Options) {
+		o.Encoding.ProtoNames = encoding.UseProtoNames
+		o.Encoding.ProtoEmitUnpopulated = encoding.EmitUnpopulated
+		o.Encoding.ProtoType = encoding.AddType
+	}
+}
+
+// RegisterEncoder registers the function used to map the attribute values
+// whose dynamic type is T to their structpb.Value equivalent, replacing any
+// previously registered for T.  Registered encoders take precedence over the
+// default mapping, which converts values that are not simply mappable to a
+// structpb.Value via a JSON round-trip, so that domain types such as money,
+// ids or decimals can be rendered as intended without implementing
+// json.Marshaler.
+//
+// Only values of exactly type T are matched, e.g. registering an encoder for
+// T does not cover *T.  Returning nil from the encoder falls back to the
+// default mapping.  Encoders are shared by all handlers and should be
+// registered during initialization.
+func RegisterEncoder[T any](encode func(T) *spb.Value) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	if t.Kind() == reflect.Interface {
+		panic("encoders cannot be registered for interface types")
+	}
+
+	if encode == nil {
+		panic("encoder is nil")
+	}
+
+	attr.RegisterEncoder(t, func(a any) *spb.Value {
+		//nolint:forcetypeassert
+		return encode(a.(T))
+	})
+}
+
+// UnregisterEncoder removes the encoder registered for T, if any.
+func UnregisterEncoder[T any]() {
+	attr.RegisterEncoder(reflect.TypeOf((*T)(nil)).Elem(), nil)
+}
@@ -0,0 +1,330 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"m4o.io/gslog"
+	"m4o.io/gslog/internal/options"
+)
+
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+type AccountID [4]byte
+
+func TestRegisterEncoder(t *testing.T) {
+	gslog.RegisterEncoder(func(m Money) *structpb.Value {
+		return structpb.NewStringValue(fmt.Sprintf("%d.%02d %s", m.Cents/100, m.Cents%100, m.Currency))
+	})
+	gslog.RegisterEncoder(func(id AccountID) *structpb.Value {
+		if id == (AccountID{}) {
+			return nil
+		}
+
+		return structpb.NewStringValue(fmt.Sprintf("acct-%x", id[:]))
+	})
+
+	defer gslog.UnregisterEncoder[Money]()
+	defer gslog.UnregisterEncoder[AccountID]()
+
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c))
+
+	l.Info("Charged.",
+		"amount", Money{Cents: 1234, Currency: "USD"},
+		"pointer", &Money{Cents: 1, Currency: "USD"},
+		"account", AccountID{0xca, 0xfe, 0xba, 0xbe},
+		"none", AccountID{},
+		slog.Group("refund", "amount", Money{Cents: 5, Currency: "EUR"}))
+
+	fields := c.entries[0].Payload.(*structpb.Struct).GetFields()
+
+	assert.Equal(t, "12.34 USD", fields["amount"].GetStringValue())
+	assert.Equal(t, "acct-cafebabe", fields["account"].GetStringValue())
+	assert.Equal(t, "0.05 EUR", fields["refund"].GetStructValue().GetFields()["amount"].GetStringValue())
+
+	// other types, and nil encodings, use the default mapping
+	assert.Equal(t, float64(1), fields["pointer"].GetStructValue().GetFields()["Cents"].GetNumberValue())
+	assert.Len(t, fields["none"].GetListValue().GetValues(), 4)
+
+	gslog.UnregisterEncoder[Money]()
+
+	l.Info("Charged.", "amount", Money{Cents: 1234, Currency: "USD"})
+
+	fields = c.entries[1].Payload.(*structpb.Struct).GetFields()
+	assert.Equal(t, "USD", fields["amount"].GetStructValue().GetFields()["Currency"].GetStringValue())
+}
+
+func TestRegisterEncoder_invalid(t *testing.T) {
+	assert.Panics(t, func() {
+		gslog.RegisterEncoder[fmt.Stringer](func(fmt.Stringer) *structpb.Value { return nil })
+	})
+	assert.Panics(t, func() {
+		gslog.RegisterEncoder[Money](nil)
+	})
+}
+
+func protoEntry() *loggingpb.LogEntry {
+	return &loggingpb.LogEntry{
+		LogName:   "projects/my-project/logs/my-log",
+		Timestamp: timestamppb.New(testTime),
+		Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "How now brown cow?"},
+	}
+}
+
+func TestProtoMessage(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c))
+
+	l.Info("Logged.", "entry", protoEntry(), "latency", durationpb.New(1500*time.Millisecond))
+
+	fields := c.entries[0].Payload.(*structpb.Struct).GetFields()
+	entry := fields["entry"].GetStructValue().GetFields()
+
+	assert.Equal(t, []string{"logName", "textPayload", "timestamp"}, sortedKeys(fields["entry"].GetStructValue()))
+	assert.Equal(t, "How now brown cow?", entry["textPayload"].GetStringValue())
+	assert.Equal(t, "2000-01-02T03:04:05Z", entry["timestamp"].GetStringValue())
+	assert.Equal(t, "1.500s", fields["latency"].GetStringValue())
+}
+
+func TestProtoMessage_invalid(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c))
+
+	// protojson refuses to marshal invalid UTF-8
+	l.Info("Logged.", "entry", &loggingpb.LogEntry{LogName: "cow\xff"})
+
+	fields := c.entries[0].Payload.(*structpb.Struct).GetFields()
+
+	assert.Contains(t, fields, "entry")
+	assert.Contains(t, fields["entry"].GetStructValue().GetFields()["log_name"].GetStringValue(), "cow")
+}
+
+func TestWithProtoEncoding(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithProtoEncoding(gslog.ProtoEncoding{
+		UseProtoNames:   true,
+		EmitUnpopulated: true,
+		AddType:         true,
+	})))
+
+	l.Info("Logged.", "entry", protoEntry())
+
+	entry := c.entries[0].Payload.(*structpb.Struct).GetFields()["entry"].GetStructValue().GetFields()
+
+	assert.Equal(t, "type.googleapis.com/google.logging.v2.LogEntry", entry["@type"].GetStringValue())
+	assert.Equal(t, "projects/my-project/logs/my-log", entry["log_name"].GetStringValue())
+	assert.Equal(t, "How now brown cow?", entry["text_payload"].GetStringValue())
+	assert.Contains(t, entry, "insert_id")
+	assert.Equal(t, "", entry["insert_id"].GetStringValue())
+}
+
+func TestWithDurationEncoding(t *testing.T) {
+	tests := map[gslog.DurationEncoding]*structpb.Value{
+		gslog.DurationNanos:   structpb.NewNumberValue(1.5e9),
+		gslog.DurationSeconds: structpb.NewNumberValue(1.5),
+		gslog.DurationProto:   structpb.NewStringValue("1.500s"),
+		gslog.DurationString:  structpb.NewStringValue("1.5s"),
+	}
+
+	for de, want := range tests {
+		c := &collector{}
+		l := slog.New(gslog.NewGcpHandler(c, gslog.WithDurationEncoding(de)))
+		l = l.With("bound", 1500*time.Millisecond).WithGroup("g")
+
+		l.Info("Slow.", "latency", 1500*time.Millisecond, slog.Group("nested", slog.Duration("latency", 1500*time.Millisecond)))
+
+		fields := c.entries[0].Payload.(*structpb.Struct).GetFields()
+		g := fields["g"].GetStructValue().GetFields()
+
+		assert.True(t, proto.Equal(want, fields["bound"]), fields["bound"].String())
+		assert.True(t, proto.Equal(want, g["latency"]), g["latency"].String())
+		assert.True(t, proto.Equal(want, g["nested"].GetStructValue().GetFields()["latency"]))
+	}
+}
+
+func TestWithTimeEncoding(t *testing.T) {
+	tm := time.Date(2000, 1, 2, 3, 4, 5, 123456789, time.FixedZone("EST", -5*60*60))
+
+	tests := map[string]struct {
+		opts []options.OptionProcessor
+		want *structpb.Value
+	}{
+		"default":     {nil, structpb.NewStringValue("2000-01-02T03:04:05.123-05:00")},
+		"utc":         {[]options.OptionProcessor{gslog.WithTimeInUTC()}, structpb.NewStringValue("2000-01-02T08:04:05.123Z")},
+		"micros":      {[]options.OptionProcessor{gslog.WithTimeEncoding(gslog.TimeRFC3339Micros)}, structpb.NewStringValue("2000-01-02T03:04:05.123456-05:00")},
+		"nanos":       {[]options.OptionProcessor{gslog.WithTimeEncoding(gslog.TimeRFC3339Nanos), gslog.WithTimeInUTC()}, structpb.NewStringValue("2000-01-02T08:04:05.123456789Z")},
+		"unix":        {[]options.OptionProcessor{gslog.WithTimeEncoding(gslog.TimeUnixSeconds)}, structpb.NewNumberValue(946800245.123456)},
+		"unix millis": {[]options.OptionProcessor{gslog.WithTimeEncoding(gslog.TimeUnixMillis)}, structpb.NewNumberValue(946800245123)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &collector{}
+			l := slog.New(gslog.NewGcpHandler(c, tc.opts...))
+			l = l.With("bound", tm).WithGroup("g")
+
+			l.Info("Now.", "at", tm)
+
+			fields := c.entries[0].Payload.(*structpb.Struct).GetFields()
+
+			assert.True(t, proto.Equal(tc.want, fields["bound"]), fields["bound"].String())
+			assert.True(t, proto.Equal(tc.want, fields["g"].GetStructValue().GetFields()["at"]))
+		})
+	}
+}
+
+func TestWithLosslessIntegers(t *testing.T) {
+	type order struct {
+		ID    uint64 `json:"id"`
+		Items int    `json:"items"`
+	}
+
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithLosslessIntegers()))
+	l = l.With("bound", uint64(1<<63)).WithGroup("g")
+
+	l.Info("Ordered.", "id", int64(-1<<62), "count", 3, "order", order{ID: 1<<53 + 1, Items: 2})
+
+	fields := c.entries[0].Payload.(*structpb.Struct).GetFields()
+	g := fields["g"].GetStructValue().GetFields()
+
+	assert.True(t, proto.Equal(structpb.NewStringValue("9223372036854775808"), fields["bound"]), fields["bound"].String())
+	assert.True(t, proto.Equal(structpb.NewStringValue("-4611686018427387904"), g["id"]), g["id"].String())
+	assert.True(t, proto.Equal(structpb.NewNumberValue(3), g["count"]), g["count"].String())
+
+	o := g["order"].GetStructValue().GetFields()
+	assert.True(t, proto.Equal(structpb.NewStringValue("9007199254740993"), o["id"]), o["id"].String())
+	assert.True(t, proto.Equal(structpb.NewNumberValue(2), o["items"]), o["items"].String())
+}
+
+func TestWithStructuredErrors(t *testing.T) {
+	notFound := func(err error) []slog.Attr {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []slog.Attr{slog.String("reason", "not_found")}
+		}
+
+		return nil
+	}
+
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithStructuredErrors(notFound)))
+
+	l.Info("Failed.", "error", fmt.Errorf("load: %w", fs.ErrNotExist))
+
+	e := c.entries[0].Payload.(*structpb.Struct).GetFields()["error"].GetStructValue().GetFields()
+
+	assert.Equal(t, "load: file does not exist", e["message"].GetStringValue())
+	assert.Equal(t, "*fmt.wrapError", e["type"].GetStringValue())
+	assert.Equal(t, "not_found", e["reason"].GetStringValue())
+	assert.Len(t, e["chain"].GetListValue().GetValues(), 1)
+}
+
+type hiddenHost struct {
+	host string
+}
+
+func (h hiddenHost) String() string {
+	return h.host
+}
+
+func TestWithConversions(t *testing.T) {
+	u := url.URL{Scheme: "https", Host: "example.com", Path: "/a"}
+	m := stringerMoney{Cents: 150, Currency: "EUR"}
+
+	// by default, Stringers are only used when the JSON is empty
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c))
+	l.Info("Fetched.", "url", u, "ip", net.IPv4(10, 0, 0, 1), "host", hiddenHost{host: "example.com"}, "price", m)
+
+	fields := c.entries[0].Payload.(*structpb.Struct).GetFields()
+	assert.Equal(t, "example.com", fields["url"].GetStructValue().GetFields()["Host"].GetStringValue())
+	assert.Equal(t, "10.0.0.1", fields["ip"].GetStringValue())
+	assert.Equal(t, "example.com", fields["host"].GetStringValue())
+	assert.Equal(t, "EUR", fields["price"].GetStructValue().GetFields()["Currency"].GetStringValue())
+
+	c = &collector{}
+	l = slog.New(gslog.NewGcpHandler(c, gslog.WithConversions()))
+	l.Info("Fetched.", "host", hiddenHost{host: "example.com"})
+
+	fields = c.entries[0].Payload.(*structpb.Struct).GetFields()
+	assert.Empty(t, fields["host"].GetStructValue().GetFields())
+}
+
+type stringerMoney struct {
+	Cents    int64
+	Currency string
+}
+
+func (m stringerMoney) String() string {
+	return fmt.Sprintf("%d %s", m.Cents, m.Currency)
+}
+
+func TestWithStructuredErrors_nil(t *testing.T) {
+	assert.PanicsWithValue(t, "error fields is nil", func() {
+		gslog.WithStructuredErrors(nil)
+	})
+}
+
+type pointerLogValuer struct {
+	N int
+}
+
+func (v *pointerLogValuer) LogValue() slog.Value {
+	return slog.AnyValue(pointerLogValuer{N: v.N})
+}
+
+type recursiveGroup struct{}
+
+func (recursiveGroup) LogValue() slog.Value {
+	return slog.GroupValue(slog.Any("self", recursiveGroup{}))
+}
+
+func TestLogValuer_recursive(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c))
+
+	l.Info("Resolved.", "pointer", pointerLogValuer{N: 1}, "group", recursiveGroup{})
+
+	fields := c.entries[0].Payload.(*structpb.Struct).GetFields()
+
+	// the resolution stops at the depth cap rather than overflowing the stack
+	assert.Contains(t, fields["pointer"].GetStringValue(), "LogValue called too many times")
+
+	g := fields["group"]
+	for g.GetStructValue() != nil {
+		g = g.GetStructValue().GetFields()["self"]
+	}
+
+	assert.Contains(t, g.GetStringValue(), "LogValue called too many times")
+}
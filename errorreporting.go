@@ -0,0 +1,119 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+
+	spb "google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog/internal/attr"
+)
+
+const (
+	// ReportedErrorEventType is the "@type" marker that directs Cloud Error
+	// Reporting to pick up a log entry.
+	ReportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+	// StackTraceKey is the payload key of the stack trace of a reported error.
+	StackTraceKey = "stack_trace"
+
+	// ServiceContextKey is the payload key of the service context of a
+	// reported error.
+	ServiceContextKey = "serviceContext"
+
+	typeKey = "@type"
+
+	maxStackDepth = 64
+)
+
+// errorReporter decorates the payloads of error records so that they are
+// picked up by Cloud Error Reporting.
+type errorReporter struct {
+	serviceContext *spb.Value
+}
+
+func newErrorReporter(service, version string) *errorReporter {
+	fields := map[string]*spb.Value{
+		"service": attr.NewStringValue(service),
+	}
+
+	if version != "" {
+		fields["version"] = attr.NewStringValue(version)
+	}
+
+	return &errorReporter{
+		serviceContext: &spb.Value{Kind: &spb.Value_StructValue{StructValue: &spb.Struct{Fields: fields}}},
+	}
+}
+
+// decorate adds the Error Reporting fields to the payload.  The stack trace
+// is taken from err, if it carries one, otherwise from the goroutine's stack,
+// starting at the frame of the logging call identified by pc.
+func (r *errorReporter) decorate(payload *spb.Struct, err error, pc uintptr) {
+	pcs, ok := attr.InnermostStackTrace(err)
+	if !ok {
+		pcs = callersFrom(pc)
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(err.Error())
+	sb.WriteString("\n\ngoroutine 1 [running]:\n")
+
+	for _, f := range attr.Frames(pcs) {
+		sb.WriteString(f.Function)
+		sb.WriteString("(...)\n\t")
+		sb.WriteString(f.File)
+		sb.WriteString(":")
+		sb.WriteString(strconv.Itoa(f.Line))
+		sb.WriteString("\n")
+	}
+
+	payload.Fields[typeKey] = attr.NewStringValue(ReportedErrorEventType)
+	payload.Fields[StackTraceKey] = attr.NewStringValue(sb.String())
+	payload.Fields[ServiceContextKey] = r.serviceContext
+}
+
+// errorFrom returns the error held by the attribute, if it holds one.
+func errorFrom(a slog.Attr) (error, bool) {
+	if a.Value.Kind() != slog.KindAny {
+		return nil, false
+	}
+
+	err, ok := a.Value.Any().(error)
+
+	return err, ok && err != nil
+}
+
+// callersFrom returns the program counters of the goroutine's stack, starting
+// at the frame identified by pc.  If pc cannot be found, the whole stack is
+// returned.
+func callersFrom(pc uintptr) []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(0, pcs)
+	pcs = pcs[:n]
+
+	for i, p := range pcs {
+		if p == pc {
+			return pcs[i:]
+		}
+	}
+
+	return pcs
+}
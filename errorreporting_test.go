@@ -0,0 +1,114 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog"
+)
+
+func newPkgError() error {
+	return errors.New("ouch")
+}
+
+func TestErrorReporting(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		level     slog.Level
+		err       error
+		reported  bool
+		wantFrame string
+	}{
+		{
+			name:      "plain error",
+			level:     slog.LevelError,
+			err:       fmt.Errorf("ouch"),
+			reported:  true,
+			wantFrame: "m4o.io/gslog_test.TestErrorReporting.func1(...)",
+		},
+		{
+			name:      "stack tracer",
+			level:     gslog.LevelCritical,
+			err:       fmt.Errorf("wrapped: %w", newPkgError()),
+			reported:  true,
+			wantFrame: "m4o.io/gslog_test.newPkgError(...)",
+		},
+		{
+			name:     "below error",
+			level:    slog.LevelWarn,
+			err:      fmt.Errorf("ouch"),
+			reported: false,
+		},
+		{
+			name:     "no error",
+			level:    slog.LevelError,
+			reported: false,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := &Got{}
+			l := slog.New(gslog.NewGcpHandler(got, gslog.WithErrorReporting("my-service", "v1.2.3")))
+
+			var args []any
+			if test.err != nil {
+				args = append(args, "error", test.err)
+			}
+
+			l.Log(ctx, test.level, "How now brown cow?", args...)
+
+			entry := got.LogEntry
+			if test.level >= gslog.LevelCritical {
+				entry = got.SyncLogEntry
+			}
+
+			fields := entry.Payload.(*structpb.Struct).GetFields()
+
+			if !test.reported {
+				assert.NotContains(t, fields, "@type")
+				assert.NotContains(t, fields, gslog.StackTraceKey)
+				assert.NotContains(t, fields, gslog.ServiceContextKey)
+
+				return
+			}
+
+			assert.Equal(t, gslog.ReportedErrorEventType, fields["@type"].GetStringValue())
+			assert.Equal(t, "How now brown cow?", fields[gslog.MessageKey].GetStringValue())
+
+			sc := fields[gslog.ServiceContextKey].GetStructValue().GetFields()
+			assert.Equal(t, "my-service", sc["service"].GetStringValue())
+			assert.Equal(t, "v1.2.3", sc["version"].GetStringValue())
+
+			st := fields[gslog.StackTraceKey].GetStringValue()
+			assert.True(t, strings.HasPrefix(st, test.err.Error()+"\n\ngoroutine 1 [running]:\n"), st)
+
+			lines := strings.Split(st, "\n")
+			assert.Equal(t, test.wantFrame, lines[3])
+		})
+	}
+}
+
+func TestWithErrorReporting_empty(t *testing.T) {
+	assert.PanicsWithValue(t, "service is empty", func() {
+		gslog.WithErrorReporting("", "")
+	})
+}
@@ -16,6 +16,7 @@ package gslog
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -25,7 +26,6 @@ import (
 	"cloud.google.com/go/logging"
 	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
 	"github.com/pkg/errors"
-	"google.golang.org/protobuf/proto"
 	spb "google.golang.org/protobuf/types/known/structpb"
 
 	"m4o.io/gslog/internal/attr"
@@ -49,10 +49,27 @@ type GcpHandler struct {
 	// of the log statement and add a SourceKey attribute to the output.
 	addSource       bool
 	entryAugmentors []options.EntryAugmentor
+	namedAugmentors []options.NamedEntryAugmentor
 	replaceAttr     attr.Mapper
-
-	payload *spb.Struct
-	groups  []string
+	encoding        *attr.Encoding
+	errorReporter   *errorReporter
+	routes          []options.Route
+	syncPolicy      options.SyncPolicy
+	onError         options.ErrorHandler
+	returnErrors    bool
+	sizeLimiter     *sizeLimiter
+	sample          options.SampleFunc
+	deduplicator    *deduplicator
+	bufferSize      int
+	bufferTrigger   slog.Level
+	callerLeveler   *callerLeveler
+
+	// payload holds the attributes bound via WithAttrs and WithGroup.  It
+	// is shared by the handlers derived from this one and MUST NOT be
+	// modified, use copyPath to obtain a modifiable copy.
+	payload     *spb.Struct
+	groups      []string
+	httpRequest *logging.HTTPRequest
 }
 
 var _ slog.Handler = (*GcpHandler)(nil)
@@ -75,12 +92,52 @@ func newGcpLoggerWithOptions(logger Logger, opts *options.Options) *GcpHandler {
 
 		addSource:       opts.AddSource,
 		entryAugmentors: opts.EntryAugmentors,
+		namedAugmentors: opts.NamedEntryAugmentors,
 		replaceAttr:     attr.WrapAttrMapper(opts.ReplaceAttr),
+		encoding:        &opts.Encoding,
+		routes:          opts.Routes,
+		syncPolicy:      opts.SyncPolicy,
+		onError:         opts.OnError,
+		returnErrors:    opts.ReturnErrors,
+		sample:          opts.Sample,
+		bufferSize:      opts.BufferSize,
+		bufferTrigger:   opts.BufferTrigger,
 
 		payload: &spb.Struct{Fields: make(map[string]*spb.Value)},
 		groups:  nil,
 	}
 
+	if handler.syncPolicy == nil {
+		handler.syncPolicy = options.SyncPolicy(SyncAtLevel(LevelCritical))
+	}
+
+	if handler.onError == nil {
+		handler.onError = printError
+	}
+
+	if len(opts.CallerLevels) > 0 {
+		handler.callerLeveler = newCallerLeveler(opts.CallerLevels)
+	}
+
+	if handler.bufferSize <= 0 {
+		handler.bufferSize = DefaultBufferSize
+	}
+
+	if opts.DedupWindow > 0 {
+		handler.deduplicator = newDeduplicator(opts.DedupWindow, handler.encoding, handler.summarize)
+	}
+
+	if opts.SizeLimit > 0 {
+		handler.sizeLimiter = &sizeLimiter{
+			limit:    opts.SizeLimit,
+			strategy: TruncationStrategy(opts.TruncationStrategy),
+		}
+	}
+
+	if opts.ErrorReportingService != "" {
+		handler.errorReporter = newErrorReporter(opts.ErrorReportingService, opts.ErrorReportingVersion)
+	}
+
 	return handler
 }
 
@@ -98,29 +155,75 @@ func (h *GcpHandler) WithLeveler(leveler slog.Leveler) *GcpHandler {
 }
 
 // Enabled reports whether the handler handles records at the given level.
-// The handler ignores records whose level is lower.
-func (h *GcpHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return h.level.Level() <= level
+// The handler ignores records whose level is lower, unless a buffer is
+// attached to the context, see WithBuffer.  A level attached to the context,
+// see WithLevel, takes precedence over the handler's.  If levels are set for
+// callers, see WithCallerLevels, records at or above the lowest of those
+// levels are handled and filtered once their caller is known.
+func (h *GcpHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if lvl, ok := levelFrom(ctx); ok {
+		return lvl <= level || buffering(ctx)
+	}
+
+	if h.level.Level() <= level {
+		return true
+	}
+
+	if h.callerLeveler != nil && h.callerLeveler.min <= level {
+		return true
+	}
+
+	return buffering(ctx)
 }
 
 // Handle will handle a slog.Record, as described in the interface's
 // documentation.  It will translate the slog.Record into a logging.Entry
-// that's filled with a *spb.Value as an Entry Payload.
+// that's filled with a *spb.Value as an Entry Payload.  Attributes holding a
+// *logging.HTTPRequest, see HTTPRequest, are set as the Entry's HTTPRequest
+// rather than added to the payload.  Records below the handler's level logged
+// using a context with a buffer attached, see WithBuffer, are buffered.
 func (h *GcpHandler) Handle(ctx context.Context, record slog.Record) error {
-	//nolint:forcetypeassert
-	payload2 := proto.Clone(h.payload).(*spb.Struct)
+	minLevel := h.minLevel(ctx, record)
 
-	if payload2.Fields == nil {
-		payload2.Fields = make(map[string]*spb.Value)
+	// records logged from a known caller may be enabled only because of the
+	// levels set for other callers, see Enabled; records without a caller,
+	// e.g. handled directly, are handled as is
+	if h.callerLeveler != nil && record.PC != 0 && record.Level < minLevel && !buffering(ctx) {
+		return nil
 	}
 
+	var dropped uint64
+
+	if h.sample != nil {
+		var keep bool
+		if keep, dropped = h.sample(record); !keep {
+			return nil
+		}
+	}
+
+	payload2, _ := copyPath(h.payload, h.groups)
+
+	httpRequest := h.httpRequest
+
+	var recordErr error
+
 	setAndClean(h.groups, payload2, func(_ []string, payload *spb.Struct) {
 		record.Attrs(func(a slog.Attr) bool {
 			if h.replaceAttr != nil {
 				a = h.replaceAttr(h.groups, a)
 			}
 
-			attr.DecorateWith(payload, a)
+			if r, ok := httpRequestFrom(a); ok {
+				httpRequest = r
+
+				return true
+			}
+
+			if err, ok := errorFrom(a); ok && recordErr == nil {
+				recordErr = err
+			}
+
+			h.encoding.DecorateWith(payload, a)
 
 			return true
 		})
@@ -131,13 +234,22 @@ func (h *GcpHandler) Handle(ctx context.Context, record slog.Record) error {
 		a = h.replaceAttr(nil, a)
 	}
 
-	attr.DecorateWith(payload2, a)
+	h.encoding.DecorateWith(payload2, a)
+
+	if dropped > 0 {
+		payload2.Fields[DroppedKey] = attr.NewNumberValue(float64(dropped))
+	}
+
+	if h.errorReporter != nil && recordErr != nil && record.Level >= slog.LevelError {
+		h.errorReporter.decorate(payload2, recordErr, record.PC)
+	}
 
 	var entry logging.Entry
 
 	entry.Payload = payload2
 	entry.Timestamp = record.Time.UTC()
 	entry.Severity = level.ToSeverity(record.Level)
+	entry.HTTPRequest = httpRequest
 
 	if h.addSource {
 		addSourceLocation(&entry, &record)
@@ -147,15 +259,96 @@ func (h *GcpHandler) Handle(ctx context.Context, record slog.Record) error {
 		b(ctx, &entry, h.groups)
 	}
 
+	buf := bufferFrom(ctx)
+	buffered := buf != nil && record.Level < minLevel
+
+	// the buffered entries are logged before the entry, and so are marked as
+	// the operation's first entry in its stead
+	var drained []bufferedEntry
+	if buf != nil && !buffered && record.Level >= h.bufferTrigger {
+		drained = buf.drain()
+		for i := range drained {
+			drained[i].operation.markFirst(&drained[i].entry)
+		}
+	}
+
 	labelsEntryAugmentorFrom(ctx)(ctx, &entry, h.groups)
+	op := addOperation(ctx, &entry, !buffered)
+	addResource(ctx, &entry)
+
+	h.augment(ctx, record, &entry)
+
+	logger := h.route(ctx, &entry)
+
+	if buffered {
+		buf.add(h.bufferSize, bufferedEntry{logger: logger, record: record.Clone(), entry: entry, operation: op})
+
+		return nil
+	}
+
+	var errs []error
+
+	for _, be := range drained {
+		if err := h.deliver(ctx, be.logger, h.shouldSync(ctx, be.record), be.record, be.entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := h.deliver(ctx, logger, h.shouldSync(ctx, record), record, entry); err != nil {
+		errs = append(errs, err)
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// deliver logs the entry built from the record to the logger, once
+// deduplicated and its size limited.
+func (h *GcpHandler) deliver(ctx context.Context, logger Logger, sync bool, record slog.Record, entry logging.Entry) error {
+	if h.deduplicator != nil && h.deduplicator.suppress(record, entry, logger) {
+		return nil
+	}
+
+	return h.limit(ctx, logger, sync, record, entry)
+}
+
+// summarize logs the summary of a burst of records suppressed via
+// WithDeduplication, the errors being reported via the handler's OnError.
+func (h *GcpHandler) summarize(logger Logger, record slog.Record, entry logging.Entry) {
+	ctx := context.Background()
+
+	_ = h.limit(ctx, logger, h.shouldSync(ctx, record), record, entry)
+}
+
+// limit logs the entry built from the record to the logger, once its size
+// limited.
+func (h *GcpHandler) limit(ctx context.Context, logger Logger, sync bool, record slog.Record, entry logging.Entry) error {
+	if h.sizeLimiter == nil {
+		return h.emit(ctx, logger, sync, record, entry)
+	}
+
+	for _, e := range h.sizeLimiter.enforce(entry) {
+		if err := h.emit(ctx, logger, sync, record, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// emit logs the entry built from the record to the logger.
+func (h *GcpHandler) emit(ctx context.Context, logger Logger, sync bool, record slog.Record, entry logging.Entry) error {
+	if !sync {
+		logger.Log(entry)
+
+		return nil
+	}
+
+	if err := logger.LogSync(ctx, entry); err != nil {
+		h.onError(err, record, entry)
 
-	if entry.Severity >= logging.Critical {
-		err := h.log.LogSync(ctx, entry)
-		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "error logging: %s\n%s", record.Message, err)
+		if h.returnErrors {
+			return errors.Wrap(err, "unable to log entry")
 		}
-	} else {
-		h.log.Log(entry)
 	}
 
 	return nil
@@ -166,14 +359,21 @@ func (h *GcpHandler) Handle(ctx context.Context, record slog.Record) error {
 func (h *GcpHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	handler2 := h.clone()
 
-	current := fromPath(handler2.payload, handler2.groups)
+	payload2, current := copyPath(h.payload, h.groups)
+	handler2.payload = payload2
 
 	for _, a := range attrs {
 		if h.replaceAttr != nil {
 			a = h.replaceAttr(h.groups, a)
 		}
 
-		attr.DecorateWith(current, a)
+		if r, ok := httpRequestFrom(a); ok {
+			handler2.httpRequest = r
+
+			continue
+		}
+
+		h.encoding.DecorateWith(current, a)
 	}
 
 	return handler2
@@ -188,13 +388,9 @@ func (h *GcpHandler) WithGroup(name string) slog.Handler {
 
 	handler2 := h.clone()
 
-	//nolint:forcetypeassert
-	payload2 := proto.Clone(h.payload).(*spb.Struct)
-
+	payload2, current := copyPath(h.payload, h.groups)
 	handler2.payload = payload2
 
-	current := fromPath(handler2.payload, handler2.groups)
-
 	current.Fields[name] = &spb.Value{
 		Kind: &spb.Value_StructValue{
 			StructValue: &spb.Struct{
@@ -209,14 +405,28 @@ func (h *GcpHandler) WithGroup(name string) slog.Handler {
 	return handler2
 }
 
-// Flush blocks until all currently buffered log entries are sent.
+// Flush blocks until all currently buffered log entries are sent, including
+// those of the Loggers configured via WithRoute and the summaries of the
+// bursts of records suppressed via WithDeduplication.
 //
 // If any errors occurred since the last call to Flush from any Logger, or the
 // creation of the client if this is the first call, then Flush returns a non-nil
 // error with summary information about the errors. This information is unlikely to
 // be actionable. For more accurate error reporting, set Client.OnError.
 func (h *GcpHandler) Flush() error {
-	if err := h.log.Flush(); err != nil {
+	if h.deduplicator != nil {
+		h.deduplicator.flush()
+	}
+
+	err := h.log.Flush()
+
+	for _, r := range h.routes {
+		if rErr := r.Logger.Flush(); rErr != nil && err == nil {
+			err = rErr
+		}
+	}
+
+	if err != nil {
 		return errors.Wrap(err, "failed to flush handler")
 	}
 
@@ -224,25 +434,39 @@ func (h *GcpHandler) Flush() error {
 }
 
 func (h *GcpHandler) clone() *GcpHandler {
-	//nolint:forcetypeassert
-	payload2 := proto.Clone(h.payload).(*spb.Struct)
-
 	return &GcpHandler{
 		log:   h.log,
 		level: h.level,
 
 		addSource:       h.addSource,
 		entryAugmentors: h.entryAugmentors,
+		namedAugmentors: h.namedAugmentors,
 		replaceAttr:     h.replaceAttr,
-
-		payload: payload2,
-		groups:  slices.Clip(h.groups),
+		encoding:        h.encoding,
+		errorReporter:   h.errorReporter,
+		routes:          h.routes,
+		syncPolicy:      h.syncPolicy,
+		onError:         h.onError,
+		returnErrors:    h.returnErrors,
+		sizeLimiter:     h.sizeLimiter,
+		sample:          h.sample,
+		deduplicator:    h.deduplicator,
+		bufferSize:      h.bufferSize,
+		bufferTrigger:   h.bufferTrigger,
+		callerLeveler:   h.callerLeveler,
+
+		payload:     h.payload,
+		groups:      slices.Clip(h.groups),
+		httpRequest: h.httpRequest,
 	}
 }
 
+func printError(err error, r slog.Record, _ logging.Entry) {
+	_, _ = fmt.Fprintf(os.Stderr, "error logging: %s\n%s", r.Message, err)
+}
+
 func addSourceLocation(e *logging.Entry, r *slog.Record) {
-	fs := runtime.CallersFrames([]uintptr{r.PC})
-	f, _ := fs.Next()
+	f := frameOf(r.PC)
 
 	e.SourceLocation = &logpb.LogEntrySourceLocation{
 		File:     f.File,
@@ -251,16 +475,40 @@ func addSourceLocation(e *logging.Entry, r *slog.Record) {
 	}
 }
 
-func fromPath(payload *spb.Struct, path []string) *spb.Struct {
+// frameOf returns the frame of the function that logged a record, identified
+// by the record's PC.
+func frameOf(pc uintptr) runtime.Frame {
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+
+	return f
+}
+
+// copyPath returns a copy of payload that shares all of its values, except
+// for the structs along path which are copied as well, so that the copy can
+// be modified along path without affecting payload.  Structs missing along
+// path are created.  The copy and the struct at the end of its path are
+// returned.
+func copyPath(payload *spb.Struct, path []string) (*spb.Struct, *spb.Struct) {
+	root := shallowCopy(payload)
+	current := root
+
 	for _, k := range path {
-		payload = payload.GetFields()[k].GetStructValue()
+		s := shallowCopy(current.GetFields()[k].GetStructValue())
+		current.Fields[k] = &spb.Value{Kind: &spb.Value_StructValue{StructValue: s}}
+		current = s
 	}
 
-	if payload.Fields == nil {
-		payload.Fields = make(map[string]*spb.Value)
+	return root, current
+}
+
+func shallowCopy(s *spb.Struct) *spb.Struct {
+	fields := make(map[string]*spb.Value, len(s.GetFields())+1)
+	for k, v := range s.GetFields() {
+		fields[k] = v
 	}
 
-	return payload
+	return &spb.Struct{Fields: fields}
 }
 
 func setAndClean(groups []string, payload *spb.Struct, decorate func(groups []string, payload *spb.Struct)) {
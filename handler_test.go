@@ -17,6 +17,8 @@ package gslog_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"path/filepath"
 	"runtime"
@@ -1188,3 +1190,148 @@ func TestJson(t *testing.T) {
 func strip(rj json.RawMessage) interface{} {
 	return rj
 }
+
+type failing struct {
+	Got
+	err error
+}
+
+func (f *failing) LogSync(_ context.Context, _ logging.Entry) error {
+	return f.err
+}
+
+func TestOnError(t *testing.T) {
+	ouch := errors.New("ouch")
+
+	var gotErr error
+	var gotRecord slog.Record
+	var gotEntry logging.Entry
+
+	onError := func(err error, r slog.Record, e logging.Entry) {
+		gotErr, gotRecord, gotEntry = err, r, e
+	}
+
+	h := gslog.NewGcpHandler(&failing{err: ouch}, gslog.WithOnError(onError))
+	l := slog.New(h)
+
+	l.Log(context.Background(), gslog.LevelCritical, "Danger, Will Robinson!")
+
+	assert.Equal(t, ouch, gotErr)
+	assert.Equal(t, "Danger, Will Robinson!", gotRecord.Message)
+	assert.Equal(t, logging.Critical, gotEntry.Severity)
+}
+
+func TestErrorsReturned(t *testing.T) {
+	ouch := errors.New("ouch")
+
+	var called bool
+
+	h := gslog.NewGcpHandler(&failing{err: ouch},
+		gslog.WithOnError(func(error, slog.Record, logging.Entry) { called = true }),
+		gslog.WithErrorsReturned())
+
+	r := slog.NewRecord(time.Now(), gslog.LevelCritical, "Danger, Will Robinson!", 0)
+
+	err := h.Handle(context.Background(), r)
+	assert.ErrorIs(t, err, ouch)
+	assert.True(t, called)
+
+	h = gslog.NewGcpHandler(&failing{err: ouch},
+		gslog.WithOnError(func(error, slog.Record, logging.Entry) {}))
+
+	assert.NoError(t, h.Handle(context.Background(), r))
+}
+
+// BenchmarkGcpHandler_Handle measures the cost of handling a record as a
+// function of the number of attributes bound to the handler via WithAttrs,
+// half of them at the root and half of them in an open group.
+func BenchmarkGcpHandler_Handle(b *testing.B) {
+	tenant := gslog.WithEntryAugmentor("tenant", func(_ context.Context, e *logging.Entry, _ []string) error {
+		if e.Labels == nil {
+			e.Labels = make(map[string]string)
+		}
+
+		e.Labels["tenant"] = "acme"
+
+		return nil
+	})
+
+	for _, n := range []int{0, 5, 20, 50} {
+		for _, augmented := range []bool{false, true} {
+			b.Run(fmt.Sprintf("bound=%d/augmented=%t", n, augmented), func(b *testing.B) {
+				var opts []options.OptionProcessor
+				if augmented {
+					opts = append(opts, tenant)
+				}
+
+				var h slog.Handler = gslog.NewGcpHandler(Discard, opts...)
+
+				bound := func(prefix string, count int) []slog.Attr {
+					attrs := make([]slog.Attr, count)
+					for i := range attrs {
+						attrs[i] = slog.String(fmt.Sprintf("%s%d", prefix, i), "value")
+					}
+					return attrs
+				}
+
+				h = h.WithAttrs(bound("root", n/2)).WithGroup("g").WithAttrs(bound("group", n-n/2))
+
+				ctx := context.Background()
+				r := slog.NewRecord(testTime, slog.LevelInfo, "How now brown cow?", 0)
+				r.AddAttrs(slog.Int("a", 1), slog.String("b", "two"))
+
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					_ = h.Handle(ctx, r)
+				}
+			})
+		}
+	}
+}
+
+func TestBoundAttrsAreShared(t *testing.T) {
+	c := &collector{}
+	h := gslog.NewGcpHandler(c, gslog.WithSizeLimit(1024, gslog.TruncateLargestStrings))
+
+	parent := slog.New(h).With("big", strings.Repeat("b", 2048)).WithGroup("g").With("a", 1)
+	child1 := parent.With("c", 1)
+	child2 := parent.WithGroup("h").With("d", 2)
+
+	child1.Info("one", "e", 3)
+	child2.Info("two")
+	parent.Info("three")
+
+	assert.Len(t, c.entries, 3)
+
+	p1 := c.entries[0].Payload.(*structpb.Struct).GetFields()
+	assert.Equal(t, []string{"a", "c", "e"}, sortedKeys(p1["g"].GetStructValue()))
+	assert.Less(t, len(p1["big"].GetStringValue()), 2048)
+
+	p2 := c.entries[1].Payload.(*structpb.Struct).GetFields()
+	assert.Equal(t, []string{"a", "h"}, sortedKeys(p2["g"].GetStructValue()))
+	assert.Equal(t, []string{"d"}, sortedKeys(p2["g"].GetStructValue().GetFields()["h"].GetStructValue()))
+
+	p3 := c.entries[2].Payload.(*structpb.Struct).GetFields()
+	assert.Equal(t, []string{"a"}, sortedKeys(p3["g"].GetStructValue()))
+
+	// the payload bound to the handlers is left untouched by the truncation
+	child1.Info("one", "e", 3)
+
+	assert.Len(t, c.entries, 4)
+	original := func(e logging.Entry) float64 {
+		fields := e.Payload.(*structpb.Struct).GetFields()
+		return fields[gslog.TruncatedKey].GetStructValue().GetFields()["originalSize"].GetNumberValue()
+	}
+	assert.Equal(t, original(c.entries[0]), original(c.entries[3]))
+}
+
+func sortedKeys(s *structpb.Struct) []string {
+	keys := make([]string, 0, len(s.GetFields()))
+	for k := range s.GetFields() {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+const (
+	// HTTPRequestKey is the key used for the HTTP request attribute created
+	// by HTTPRequest.
+	HTTPRequestKey = "httpRequest"
+)
+
+// HTTPRequest returns a slog.Attr describing an HTTP request that has been
+// served.  When logged through a GcpHandler, the attribute is lifted out of
+// the payload and into the logging.Entry's HTTPRequest field, which allows
+// Cloud Logging to display the entry as a request log.
+//
+// Any attribute whose value is a *logging.HTTPRequest is treated the same way,
+// regardless of its key, so that the less common fields, such as the cache
+// related ones, can be supplied.  Such a *logging.HTTPRequest MUST have its
+// Request set, otherwise it is added to the payload as any other value.
+//
+// If req is nil, an empty slog.Attr is returned, which handlers ignore.
+func HTTPRequest(req *http.Request, status int, responseSize int64, latency time.Duration) slog.Attr {
+	if req == nil {
+		//nolint:exhaustruct
+		return slog.Attr{}
+	}
+
+	//nolint:exhaustruct
+	r := &logging.HTTPRequest{
+		Request:      req,
+		Status:       status,
+		ResponseSize: responseSize,
+		Latency:      latency,
+	}
+
+	if req.ContentLength > 0 {
+		r.RequestSize = req.ContentLength
+	}
+
+	r.RemoteIP = remoteIP(req)
+
+	return slog.Any(HTTPRequestKey, r)
+}
+
+// httpRequestFrom returns the *logging.HTTPRequest held by the attribute, if
+// it holds one.
+func httpRequestFrom(a slog.Attr) (*logging.HTTPRequest, bool) {
+	if a.Value.Kind() != slog.KindAny {
+		return nil, false
+	}
+
+	r, ok := a.Value.Any().(*logging.HTTPRequest)
+	if !ok || r == nil || r.Request == nil {
+		return nil, false
+	}
+
+	return r, true
+}
+
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
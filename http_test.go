@@ -0,0 +1,96 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/cow", strings.NewReader("how now"))
+
+	a := gslog.HTTPRequest(req, http.StatusCreated, 512, time.Second)
+
+	assert.Equal(t, gslog.HTTPRequestKey, a.Key)
+
+	r, ok := a.Value.Any().(*logging.HTTPRequest)
+	assert.True(t, ok)
+	assert.Same(t, req, r.Request)
+	assert.Equal(t, http.StatusCreated, r.Status)
+	assert.Equal(t, int64(512), r.ResponseSize)
+	assert.Equal(t, int64(7), r.RequestSize)
+	assert.Equal(t, time.Second, r.Latency)
+	assert.Equal(t, "192.0.2.1", r.RemoteIP)
+}
+
+func TestHTTPRequest_nilRequest(t *testing.T) {
+	a := gslog.HTTPRequest(nil, http.StatusOK, 0, 0)
+	assert.True(t, a.Equal(slog.Attr{}))
+
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c))
+	l.Info("Served.", gslog.HTTPRequest(nil, http.StatusOK, 0, 0))
+
+	assert.Nil(t, c.entries[0].HTTPRequest)
+	assert.NotContains(t, c.entries[0].Payload.(*structpb.Struct).GetFields(), gslog.HTTPRequestKey)
+	assert.NotContains(t, c.entries[0].Payload.(*structpb.Struct).GetFields(), "")
+}
+
+func TestGcpHandler_Handle_httpRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/cow", nil)
+
+	got := &Got{}
+	l := slog.New(gslog.NewGcpHandler(got))
+
+	l.Info("How now brown cow?", gslog.HTTPRequest(req, http.StatusOK, 1024, time.Second), "a", 1)
+
+	assert.NotNil(t, got.LogEntry.HTTPRequest)
+	assert.Same(t, req, got.LogEntry.HTTPRequest.Request)
+	assert.Equal(t, http.StatusOK, got.LogEntry.HTTPRequest.Status)
+
+	fields := got.LogEntry.Payload.(*structpb.Struct).GetFields()
+	assert.NotContains(t, fields, gslog.HTTPRequestKey)
+	assert.Contains(t, fields, "a")
+}
+
+func TestGcpHandler_WithAttrs_httpRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/cow", nil)
+
+	got := &Got{}
+	l := slog.New(gslog.NewGcpHandler(got))
+	l = l.With(slog.Any("request", &logging.HTTPRequest{Request: req, CacheHit: true}))
+
+	l.Info("How now brown cow?")
+
+	assert.NotNil(t, got.LogEntry.HTTPRequest)
+	assert.True(t, got.LogEntry.HTTPRequest.CacheHit)
+	assert.NotContains(t, got.LogEntry.Payload.(*structpb.Struct).GetFields(), "request")
+
+	l.Info("How now brown cow?", gslog.HTTPRequest(req, http.StatusNotFound, 0, 0))
+
+	assert.False(t, got.LogEntry.HTTPRequest.CacheHit)
+	assert.Equal(t, http.StatusNotFound, got.LogEntry.HTTPRequest.Status)
+}
@@ -22,13 +22,21 @@ import (
 	"bytes"
 	"encoding/json"
 	"log/slog"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	spb "google.golang.org/protobuf/types/known/structpb"
 )
 
+const (
+	typeURLPrefix     = "type.googleapis.com/"
+	rfc3339NanosFixed = "2006-01-02T15:04:05.000000000Z07:00"
+)
+
 //nolint:gochecknoglobals
 var nilValue = &spb.Value{Kind: &spb.Value_NullValue{NullValue: spb.NullValue_NULL_VALUE}}
 
@@ -73,24 +81,159 @@ func WrapAttrMapper(mapper Mapper) Mapper {
 	return wrapped
 }
 
+// DurationEncoding identifies how time.Duration values are mapped.
+type DurationEncoding int
+
+const (
+	// DurationNanos maps durations to their number of nanoseconds.
+	DurationNanos DurationEncoding = iota
+	// DurationSeconds maps durations to their number of seconds, as a
+	// floating point number.
+	DurationSeconds
+	// DurationProto maps durations to strings following the protojson
+	// convention for google.protobuf.Duration, e.g. "1.500s".
+	DurationProto
+	// DurationString maps durations to the strings returned by
+	// time.Duration's String method, e.g. "1.5s".
+	DurationString
+)
+
+// TimeEncoding identifies how time.Time values are mapped.
+type TimeEncoding int
+
+const (
+	// TimeRFC3339Millis maps times to RFC3339 strings with millisecond
+	// precision.
+	TimeRFC3339Millis TimeEncoding = iota
+	// TimeRFC3339Micros maps times to RFC3339 strings with microsecond
+	// precision.
+	TimeRFC3339Micros
+	// TimeRFC3339Nanos maps times to RFC3339 strings with nanosecond
+	// precision.
+	TimeRFC3339Nanos
+	// TimeUnixSeconds maps times to the number of seconds elapsed since
+	// the Unix epoch, as a floating point number.
+	TimeUnixSeconds
+	// TimeUnixMillis maps times to the number of milliseconds elapsed since
+	// the Unix epoch.
+	TimeUnixMillis
+)
+
+// Encoding configures how slog.Value values are mapped to their spb.Value
+// equivalents.  The zero Encoding is the default mapping.
+type Encoding struct {
+	// ProtoNames causes proto.Message values to be mapped using their proto
+	// field names rather than their lowerCamelCase JSON names.
+	ProtoNames bool
+
+	// ProtoEmitUnpopulated causes the unpopulated fields of proto.Message
+	// values to be mapped with their default values.
+	ProtoEmitUnpopulated bool
+
+	// ProtoType causes proto.Message values to be mapped with an "@type"
+	// field holding the URL of their message type.
+	ProtoType bool
+
+	// Duration is how time.Duration values are mapped.
+	Duration DurationEncoding
+
+	// Time is how time.Time values are mapped.
+	Time TimeEncoding
+
+	// TimeUTC causes time.Time values mapped to strings to be converted to
+	// UTC rather than keeping their original zone.
+	TimeUTC bool
+
+	// LosslessIntegers causes integers outside the range of integers that
+	// can be exactly represented by a float64, i.e. ±(2^53-1), to be mapped
+	// to decimal strings rather than to numbers.
+	LosslessIntegers bool
+
+	// StructuredErrors causes error values to be mapped to structs holding
+	// their message, type, chain of wrapped errors and stack trace rather
+	// than to their Error() strings, see NewErrorValue.
+	StructuredErrors bool
+
+	// ErrorFields are called to add fields to the structs error values are
+	// mapped to when StructuredErrors is set.
+	ErrorFields []ErrorFields
+
+	// Conversions is the precedence of the interfaces honored when mapping
+	// values that are neither registered with an Encoder, proto.Message
+	// values nor errors.  If nil, DefaultConversions is used; if empty, none
+	// are honored.
+	Conversions []Conversion
+}
+
+//nolint:gochecknoglobals
+var defaultEncoding = &Encoding{}
+
+// DecorateWith will add the attribute to the spb.Struct's Fields using the
+// default Encoding, see Encoding.DecorateWith.
+func DecorateWith(payload *spb.Struct, attr slog.Attr) {
+	defaultEncoding.DecorateWith(payload, attr)
+}
+
+// ValToStruct creates the spb.Value equivalent of the supplied slog.Value
+// value using the default Encoding.
+func ValToStruct(v slog.Value) (*spb.Value, bool) {
+	return defaultEncoding.ValToStruct(v)
+}
+
+// NewGroupValue creates the spb.Value equivalent of the supplied slog.Attr
+// array using the default Encoding.
+func NewGroupValue(g []slog.Attr) *spb.Value {
+	return defaultEncoding.NewGroupValue(g)
+}
+
+// NewAny creates the spb.Value equivalent of the supplied any instance using
+// the default Encoding.
+func NewAny(a any) (*spb.Value, bool) {
+	return defaultEncoding.NewAny(a)
+}
+
 // DecorateWith will add the attribute to the spb.Struct's Fields.  If the
 // attribute cannot be mapped to a spb.Value, nothing is done. Attributes
 // of type slog.AnyAttribute are mapped using the following precedence.
 //
+//   - If an Encoder is registered for its type, the Encoder is used.
+//   - If a proto.Message, it is mapped following the protojson conventions.
 //   - If of type builtin.error and does not implement json.Marshaler, the
-//     Error() string is used.
+//     Error() string is used, or the struct created by NewErrorValue if
+//     StructuredErrors is set.
+//   - If it implements slog.LogValuer or json.Marshaler, and the Encoding's
+//     Conversions include them, the first one implemented is used.
 //   - If attribute can be simply mappable to a spb.Value, that value is
 //     used.
-//   - If the attribute can be converted into a JSON object, that JSON object is
-//     translated to its corresponding spb.Struct.
+//   - If a byte slice or array implementing encoding.TextMarshaler or
+//     fmt.Stringer, and the Encoding's Conversions include them, the first
+//     one implemented is used.
+//   - If the attribute can be converted into a JSON object, other than an
+//     empty one, that JSON object is translated to its corresponding
+//     spb.Struct.
+//   - If it implements encoding.TextMarshaler or fmt.Stringer, and the
+//     Encoding's Conversions include them, the first one implemented is used.
+//   - If the attribute can be converted into an empty JSON object, the empty
+//     spb.Struct is used.
 //   - Nothing is done.
-func DecorateWith(payload *spb.Struct, attr slog.Attr) {
-	rv := attr.Value.Resolve()
+func (e *Encoding) DecorateWith(payload *spb.Struct, attr slog.Attr) {
+	e.decorateWith(payload, attr, 0)
+}
+
+// decorateWith adds the attribute to the payload, as DecorateWith does, depth
+// being the number of slog.LogValuer values resolved to reach it.
+func (e *Encoding) decorateWith(payload *spb.Struct, attr slog.Attr, depth int) {
+	rv := attr.Value
+	if rv.Kind() == slog.KindLogValuer {
+		depth++
+		rv = resolve(rv, depth)
+	}
+
 	if attr.Key == "" && rv.Any() == nil {
 		return
 	}
 
-	val, ok := ValToStruct(rv)
+	val, ok := e.valToStruct(rv, depth)
 	if !ok {
 		return
 	}
@@ -107,30 +250,35 @@ func DecorateWith(payload *spb.Struct, attr slog.Attr) {
 // ValToStruct creates the spb.Value equivalent of the supplied slog.Value value.
 //
 //nolint:cyclop
-func ValToStruct(v slog.Value) (*spb.Value, bool) {
+func (e *Encoding) ValToStruct(v slog.Value) (*spb.Value, bool) {
+	return e.valToStruct(v, 0)
+}
+
+//nolint:cyclop
+func (e *Encoding) valToStruct(v slog.Value, depth int) (*spb.Value, bool) {
 	switch v.Kind() {
 	case slog.KindString:
 		return NewStringValue(v.String()), true
 	case slog.KindInt64:
-		return NewNumberValue(float64(v.Int64())), true
+		return e.NewIntValue(v.Int64()), true
 	case slog.KindUint64:
-		return NewNumberValue(float64(v.Uint64())), true
+		return e.NewUintValue(v.Uint64()), true
 	case slog.KindFloat64:
 		return NewNumberValue(v.Float64()), true
 	case slog.KindBool:
 		return NewBoolValue(v.Bool()), true
 	case slog.KindDuration:
-		return NewNumberValue(float64(v.Duration())), true
+		return e.NewDurationValue(v.Duration()), true
 	case slog.KindTime:
-		return NewTimeValue(v.Time()), true
+		return e.NewTimeValue(v.Time()), true
 	case slog.KindGroup:
 		if len(v.Group()) == 0 {
 			return nil, false
 		}
 
-		return NewGroupValue(v.Group()), true
+		return e.newGroupValue(v.Group(), depth), true
 	case slog.KindAny:
-		return NewAny(v.Any())
+		return e.newAny(v.Any(), depth)
 	default:
 		return nil, false
 	}
@@ -157,35 +305,179 @@ func NewBoolValue(b bool) *spb.Value {
 }
 
 // NewGroupValue creates the spb.Value equivalent of the supplied slog.Attr array.
-func NewGroupValue(g []slog.Attr) *spb.Value {
+func (e *Encoding) NewGroupValue(g []slog.Attr) *spb.Value {
+	return e.newGroupValue(g, 0)
+}
+
+func (e *Encoding) newGroupValue(g []slog.Attr, depth int) *spb.Value {
 	p := &spb.Struct{Fields: make(map[string]*spb.Value)}
 	for _, b := range g {
-		DecorateWith(p, b)
+		e.decorateWith(p, b, depth)
 	}
 
 	return &spb.Value{Kind: &spb.Value_StructValue{StructValue: p}}
 }
 
-// NewAny creates the spb.Value equivalent of the supplied any instance.
-func NewAny(a any) (*spb.Value, bool) {
+// NewAny creates the spb.Value equivalent of the supplied any instance.  The
+// Encoder registered for the instance's type, see RegisterEncoder, takes
+// precedence over the default mapping.
+func (e *Encoding) NewAny(a any) (*spb.Value, bool) {
+	return e.newAny(a, 0)
+}
+
+// newAny creates the spb.Value equivalent of the supplied any instance, as
+// NewAny does, depth being the number of slog.LogValuer values resolved to
+// reach it.
+func (e *Encoding) newAny(a any, depth int) (*spb.Value, bool) {
+	if v, ok := encode(a); ok {
+		return v, true
+	}
+
+	if m, ok := a.(proto.Message); ok {
+		return e.NewProtoValue(m)
+	}
+
 	// if value is an error, but not a JSON marshaller, return error
 	_, jm := a.(json.Marshaler)
 	if err, ok := a.(error); ok && !jm {
+		if e.StructuredErrors {
+			return e.NewErrorValue(err), true
+		}
+
 		return &spb.Value{Kind: &spb.Value_StringValue{StringValue: err.Error()}}, true
 	}
 
+	if v, ok := e.convert(a, depth); ok {
+		return v, true
+	}
+
 	// value may be simply mappable to a spb.Value.
-	if nv, err := spb.NewValue(a); err == nil {
+	if nv, ok := e.newValue(a); ok {
 		return nv, true
 	}
 
-	// try converting to a JSON object
-	return AsJSON(a)
+	// try converting to a JSON object, or else a string
+	return e.fromJSONOrText(a)
 }
 
-// NewTimeValue creates the spb.Value equivalent of the supplied time.Time instance.
+// NewProtoValue creates the spb.Value equivalent of the supplied
+// proto.Message, following the protojson conventions: field names, oneofs and
+// well-known types are mapped as protojson does.  Messages that protojson
+// cannot marshal, e.g. holding invalid UTF-8 strings, are mapped as AsJSON
+// does.
+func (e *Encoding) NewProtoValue(m proto.Message) (*spb.Value, bool) {
+	//nolint:exhaustruct
+	b, err := protojson.MarshalOptions{
+		UseProtoNames:   e.ProtoNames,
+		EmitUnpopulated: e.ProtoEmitUnpopulated,
+	}.Marshal(m)
+	if err != nil {
+		return e.AsJSON(m)
+	}
+
+	//nolint:exhaustruct
+	v := &spb.Value{}
+	if err := protojson.Unmarshal(b, v); err != nil {
+		return e.AsJSON(m)
+	}
+
+	if s := v.GetStructValue(); s != nil && e.ProtoType {
+		s.Fields["@type"] = NewStringValue(typeURLPrefix + string(m.ProtoReflect().Descriptor().FullName()))
+	}
+
+	return v, true
+}
+
+// NewDurationValue creates the spb.Value equivalent of the supplied
+// time.Duration, per the Encoding's DurationEncoding.
+func (e *Encoding) NewDurationValue(d time.Duration) *spb.Value {
+	switch e.Duration {
+	case DurationSeconds:
+		return NewNumberValue(d.Seconds())
+	case DurationProto:
+		return NewStringValue(DurationToProto(d))
+	case DurationString:
+		return NewStringValue(d.String())
+	case DurationNanos:
+		fallthrough
+	default:
+		return NewNumberValue(float64(d))
+	}
+}
+
+// DurationToProto formats a time.Duration following the protojson convention
+// for google.protobuf.Duration: seconds with 0, 3, 6 or 9 fractional digits
+// and an "s" suffix, e.g. "1.500s".
+func DurationToProto(d time.Duration) string {
+	const groupDigits = 3
+
+	var buf []byte
+
+	if d < 0 {
+		buf = append(buf, '-')
+	}
+
+	secs := d / time.Second
+	nanos := d % time.Second
+
+	if secs < 0 {
+		secs = -secs
+	}
+
+	if nanos < 0 {
+		nanos = -nanos
+	}
+
+	buf = strconv.AppendInt(buf, int64(secs), 10)
+
+	if nanos != 0 {
+		frac := strconv.AppendInt(nil, int64(nanos)+int64(time.Second), 10)[1:]
+		for len(frac) > groupDigits && string(frac[len(frac)-groupDigits:]) == "000" {
+			frac = frac[:len(frac)-groupDigits]
+		}
+
+		buf = append(buf, '.')
+		buf = append(buf, frac...)
+	}
+
+	buf = append(buf, 's')
+
+	return string(buf)
+}
+
+// NewTimeValue creates the spb.Value equivalent of the supplied time.Time
+// instance using the default Encoding.
 func NewTimeValue(t time.Time) *spb.Value {
-	return &spb.Value{Kind: &spb.Value_StringValue{StringValue: TimeToRFC3339InMs(t)}}
+	return defaultEncoding.NewTimeValue(t)
+}
+
+// NewTimeValue creates the spb.Value equivalent of the supplied time.Time
+// instance, per the Encoding's TimeEncoding.
+func (e *Encoding) NewTimeValue(t time.Time) *spb.Value {
+	if e.TimeUTC {
+		t = t.UTC()
+	}
+
+	switch e.Time {
+	case TimeRFC3339Micros:
+		return NewStringValue(TimeToRFC3339(t, time.Microsecond))
+	case TimeRFC3339Nanos:
+		return NewStringValue(TimeToRFC3339(t, time.Nanosecond))
+	case TimeUnixSeconds:
+		return NewNumberValue(float64(t.UnixMicro()) / float64(time.Second/time.Microsecond))
+	case TimeUnixMillis:
+		return NewNumberValue(float64(t.UnixMilli()))
+	case TimeRFC3339Millis:
+		fallthrough
+	default:
+		return NewStringValue(TimeToRFC3339(t, time.Millisecond))
+	}
+}
+
+// AsJSON attempts to convert the attribute a to a corresponding spb.Value
+// using the default Encoding, see Encoding.AsJSON.
+func AsJSON(a any) (*spb.Value, bool) {
+	return defaultEncoding.AsJSON(a)
 }
 
 // AsJSON attempts to convert the attribute a to a corresponding spb.Value
@@ -193,16 +485,20 @@ func NewTimeValue(t time.Time) *spb.Value {
 // corresponding spb.Value.  The function also returns true for ok if the
 // attribute can be first converted to JSON before being mapped, and false
 // otherwise.
-func AsJSON(a any) (*spb.Value, bool) {
+func (e *Encoding) AsJSON(a any) (*spb.Value, bool) {
 	if a == nil {
 		return nilValue, true
 	}
 
-	a, err := ToJSON(a)
+	a, err := toJSON(a, e.LosslessIntegers)
 	if err != nil {
 		return nil, false
 	}
 
+	if e.LosslessIntegers {
+		return e.fromJSON(a), true
+	}
+
 	value, _ := spb.NewValue(a)
 
 	return value, true
@@ -211,6 +507,12 @@ func AsJSON(a any) (*spb.Value, bool) {
 // ToJSON converts an instance of any to a JSON object map[string]interface{}.
 // An error is returned if the instance cannot be encoded into JSON.
 func ToJSON(a any) (any, error) {
+	return toJSON(a, false)
+}
+
+// toJSON converts an instance of any to a JSON object, its numbers decoded as
+// json.Number rather than float64 if useNumber is set.
+func toJSON(a any, useNumber bool) (any, error) {
 	var buf bytes.Buffer
 
 	enc := json.NewEncoder(&buf)
@@ -219,8 +521,13 @@ func ToJSON(a any) (any, error) {
 		return nil, errors.Wrap(err, "unable to encode attr")
 	}
 
+	dec := json.NewDecoder(&buf)
+	if useNumber {
+		dec.UseNumber()
+	}
+
 	var result any
-	_ = json.Unmarshal(buf.Bytes(), &result)
+	_ = dec.Decode(&result)
 
 	return result, nil
 }
@@ -238,6 +545,14 @@ var timePool = sync.Pool{
 // TimeToRFC3339InMs formats an instance of time.Time to an RFC3339 defined
 // layout in milliseconds in a performant manner.
 func TimeToRFC3339InMs(t time.Time) string {
+	return TimeToRFC3339(t, time.Millisecond)
+}
+
+// TimeToRFC3339 formats an instance of time.Time to an RFC3339 defined layout
+// with a fixed number of fractional digits, per the precision, in a
+// performant manner.  The precision is one of time.Millisecond,
+// time.Microsecond or time.Nanosecond.
+func TimeToRFC3339(t time.Time, precision time.Duration) string {
 	//nolint:forcetypeassert
 	ptr := timePool.Get().(*[]byte)
 
@@ -249,23 +564,39 @@ func TimeToRFC3339InMs(t time.Time) string {
 		timePool.Put(ptr)
 	}()
 
-	buf = append(buf, byte('"'))
+	buf = appendRFC3339(buf, t, precision)
+
+	return string(buf)
+}
+
+func appendRFC3339(buf []byte, t time.Time, precision time.Duration) []byte {
+	var digits int
+
+	switch precision {
+	case time.Nanosecond:
+		// no digit can be added to guarantee the number of digits
+		return t.AppendFormat(buf, rfc3339NanosFixed)
+	case time.Microsecond:
+		digits = 6
+	default:
+		precision = time.Millisecond
+		digits = 3
+	}
 
 	// Format according to time.RFC3339Nano since it is highly optimized,
-	// but truncate it to use millisecond resolution.
-	const prefixLen = len("2006-01-02T15:04:05.000")
+	// but truncate it to the precision.
+	prefixLen := len("2006-01-02T15:04:05.") + digits
 
-	// Unfortunately, that format trims trailing 0s, so add 1/10 millisecond
-	// to guarantee that there are exactly 4 digits after the period.
-	const rounding = time.Millisecond / 10
+	// Unfortunately, that format trims trailing 0s, so add 1/10 of the
+	// precision to guarantee that there is exactly one more digit after the
+	// period.
+	rounding := precision / 10
 
 	n := len(buf)
 
-	t = t.Truncate(time.Millisecond).Add(rounding)
+	t = t.Truncate(precision).Add(rounding)
 
 	buf = t.AppendFormat(buf, time.RFC3339Nano)
-	buf = append(buf[:n+prefixLen], buf[n+prefixLen+1:]...) // drop the 4th digit
-	buf = append(buf, byte('"'))
 
-	return string(buf)
+	return append(buf[:n+prefixLen], buf[n+prefixLen+1:]...) // drop the extra digit
 }
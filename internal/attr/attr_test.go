@@ -19,11 +19,15 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
+	"math/big"
+	"net"
+	"net/url"
 	"reflect"
 	"strconv"
 	"testing"
 	"time"
 
+	pkgerrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/types/known/structpb"
 
@@ -299,7 +303,7 @@ func TestWriteTimeRFC3339(t *testing.T) {
 		time.Date(2000, 11, 12, 3, 4, 500, 5e7, time.UTC),
 	} {
 		got := attr.TimeToRFC3339InMs(tm)
-		want := `"` + tm.Format(rfc3339Millis) + `"`
+		want := tm.Format(rfc3339Millis)
 		if got != want {
 			t.Errorf("got %s, want %s", got, want)
 		}
@@ -313,3 +317,309 @@ func BenchmarkWriteTime(b *testing.B) {
 		attr.TimeToRFC3339InMs(tm)
 	}
 }
+
+func TestEncoding_NewDurationValue(t *testing.T) {
+	d := 1500 * time.Millisecond
+
+	tests := map[attr.DurationEncoding]*structpb.Value{
+		attr.DurationNanos:   attr.NewNumberValue(1.5e9),
+		attr.DurationSeconds: attr.NewNumberValue(1.5),
+		attr.DurationProto:   attr.NewStringValue("1.500s"),
+		attr.DurationString:  attr.NewStringValue("1.5s"),
+	}
+
+	for de, want := range tests {
+		e := attr.Encoding{Duration: de}
+		assert.Equal(t, want, e.NewDurationValue(d))
+	}
+}
+
+func TestDurationToProto(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                                  "0s",
+		time.Second:                        "1s",
+		1500 * time.Millisecond:            "1.500s",
+		-1500 * time.Millisecond:           "-1.500s",
+		-time.Millisecond:                  "-0.001s",
+		time.Second + time.Microsecond:     "1.000001s",
+		time.Second + time.Nanosecond:      "1.000000001s",
+		3*time.Hour + 120*time.Microsecond: "10800.000120s",
+	}
+
+	for d, want := range tests {
+		assert.Equal(t, want, attr.DurationToProto(d), d.String())
+	}
+}
+
+func TestTimeToRFC3339(t *testing.T) {
+	for _, tm := range []time.Time{
+		time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(2000, 1, 2, 3, 4, 5, 400, time.Local),
+		time.Date(2000, 11, 12, 3, 4, 5, 123456789, time.FixedZone("EST", -5*60*60)),
+	} {
+		assert.Equal(t, tm.Format("2006-01-02T15:04:05.000000Z07:00"), attr.TimeToRFC3339(tm, time.Microsecond))
+		assert.Equal(t, tm.Format("2006-01-02T15:04:05.000000000Z07:00"), attr.TimeToRFC3339(tm, time.Nanosecond))
+	}
+}
+
+func TestEncoding_NewTimeValue(t *testing.T) {
+	tm := time.Date(2000, 1, 2, 3, 4, 5, 123456789, time.FixedZone("EST", -5*60*60))
+
+	tests := map[string]struct {
+		encoding attr.Encoding
+		want     *structpb.Value
+	}{
+		"default":     {attr.Encoding{}, attr.NewStringValue("2000-01-02T03:04:05.123-05:00")},
+		"utc":         {attr.Encoding{TimeUTC: true}, attr.NewStringValue("2000-01-02T08:04:05.123Z")},
+		"micros":      {attr.Encoding{Time: attr.TimeRFC3339Micros}, attr.NewStringValue("2000-01-02T03:04:05.123456-05:00")},
+		"nanos":       {attr.Encoding{Time: attr.TimeRFC3339Nanos, TimeUTC: true}, attr.NewStringValue("2000-01-02T08:04:05.123456789Z")},
+		"unix":        {attr.Encoding{Time: attr.TimeUnixSeconds}, attr.NewNumberValue(946800245.123456)},
+		"unix millis": {attr.Encoding{Time: attr.TimeUnixMillis}, attr.NewNumberValue(946800245123)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.encoding.NewTimeValue(tm))
+		})
+	}
+}
+
+func TestEncoding_NewIntValue(t *testing.T) {
+	lossless := attr.Encoding{LosslessIntegers: true}
+	lossy := attr.Encoding{}
+
+	assert.Equal(t, attr.NewNumberValue(attr.MaxSafeInteger), lossless.NewIntValue(attr.MaxSafeInteger))
+	assert.Equal(t, attr.NewNumberValue(-attr.MaxSafeInteger), lossless.NewIntValue(-attr.MaxSafeInteger))
+	assert.Equal(t, attr.NewStringValue("9007199254740993"), lossless.NewIntValue(attr.MaxSafeInteger+2))
+	assert.Equal(t, attr.NewStringValue("-9223372036854775808"), lossless.NewIntValue(math.MinInt64))
+	assert.Equal(t, attr.NewStringValue("18446744073709551615"), lossless.NewUintValue(math.MaxUint64))
+	assert.Equal(t, attr.NewNumberValue(42), lossless.NewUintValue(42))
+
+	assert.Equal(t, attr.NewNumberValue(float64(attr.MaxSafeInteger+2)), lossy.NewIntValue(attr.MaxSafeInteger+2))
+}
+
+func TestEncoding_AsJSON_losslessIntegers(t *testing.T) {
+	type account struct {
+		ID      uint64         `json:"id"`
+		Balance float64        `json:"balance"`
+		Limits  []int64        `json:"limits"`
+		Extra   map[string]any `json:"extra"`
+	}
+
+	a := account{
+		ID:      math.MaxUint64,
+		Balance: 12.5,
+		Limits:  []int64{1, math.MaxInt64},
+		Extra:   map[string]any{"big": int64(attr.MaxSafeInteger + 2), "exp": 1e21},
+	}
+
+	e := attr.Encoding{LosslessIntegers: true}
+
+	v, ok := e.AsJSON(a)
+	assert.True(t, ok)
+
+	fields := v.GetStructValue().GetFields()
+	assert.Equal(t, "18446744073709551615", fields["id"].GetStringValue())
+	assert.InDelta(t, 12.5, fields["balance"].GetNumberValue(), 0)
+
+	limits := fields["limits"].GetListValue().GetValues()
+	assert.InDelta(t, 1, limits[0].GetNumberValue(), 0)
+	assert.Equal(t, "9223372036854775807", limits[1].GetStringValue())
+
+	extra := fields["extra"].GetStructValue().GetFields()
+	assert.Equal(t, "9007199254740993", extra["big"].GetStringValue())
+	assert.InDelta(t, 1e21, extra["exp"].GetNumberValue(), 0)
+}
+
+func TestEncoding_NewAny_losslessIntegers(t *testing.T) {
+	e := attr.Encoding{LosslessIntegers: true}
+
+	v, ok := e.NewAny(map[string]any{"id": uint64(math.MaxUint64), "list": []any{int64(math.MinInt64), 7}})
+	assert.True(t, ok)
+
+	fields := v.GetStructValue().GetFields()
+	assert.Equal(t, "18446744073709551615", fields["id"].GetStringValue())
+	assert.Equal(t, "-9223372036854775808", fields["list"].GetListValue().GetValues()[0].GetStringValue())
+	assert.InDelta(t, 7, fields["list"].GetListValue().GetValues()[1].GetNumberValue(), 0)
+}
+
+type codeError struct {
+	code int
+}
+
+func (e codeError) Error() string {
+	return "code " + strconv.Itoa(e.code)
+}
+
+func TestEncoding_NewErrorValue(t *testing.T) {
+	e := attr.Encoding{StructuredErrors: true}
+
+	err := pkgerrors.Wrap(fmt.Errorf("charge: %w", pkgerrors.New("declined")), "unable to pay")
+
+	v, ok := e.NewAny(err)
+	assert.True(t, ok)
+
+	fields := v.GetStructValue().GetFields()
+	assert.Equal(t, "unable to pay: charge: declined", fields[attr.ErrorMessageKey].GetStringValue())
+	assert.Equal(t, "*errors.withStack", fields[attr.ErrorTypeKey].GetStringValue())
+
+	chain := fields[attr.ErrorChainKey].GetListValue().GetValues()
+	assert.Len(t, chain, 3)
+	assert.Equal(t, "*fmt.wrapError", chain[1].GetStructValue().GetFields()[attr.ErrorTypeKey].GetStringValue())
+	assert.Equal(t, "declined", chain[2].GetStructValue().GetFields()[attr.ErrorMessageKey].GetStringValue())
+
+	// the stack trace is that of the innermost error, created in this test
+	stack := fields[attr.ErrorStackTraceKey].GetListValue().GetValues()
+	assert.NotEmpty(t, stack)
+	assert.Contains(t, stack[0].GetStringValue(), "attr_test.TestEncoding_NewErrorValue")
+}
+
+func TestEncoding_NewErrorValue_joined(t *testing.T) {
+	e := attr.Encoding{StructuredErrors: true}
+
+	v := e.NewErrorValue(errors.Join(errors.New("first"), codeError{code: 7}))
+
+	fields := v.GetStructValue().GetFields()
+	assert.Equal(t, "*errors.joinError", fields[attr.ErrorTypeKey].GetStringValue())
+	assert.NotContains(t, fields, attr.ErrorChainKey)
+	assert.NotContains(t, fields, attr.ErrorStackTraceKey)
+
+	errs := fields[attr.ErrorErrorsKey].GetListValue().GetValues()
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "first", errs[0].GetStructValue().GetFields()[attr.ErrorMessageKey].GetStringValue())
+	assert.Equal(t, "attr_test.codeError", errs[1].GetStructValue().GetFields()[attr.ErrorTypeKey].GetStringValue())
+}
+
+func TestEncoding_NewErrorValue_fields(t *testing.T) {
+	e := attr.Encoding{
+		StructuredErrors: true,
+		ErrorFields: []attr.ErrorFields{func(err error) []slog.Attr {
+			var ce codeError
+			if !errors.As(err, &ce) {
+				return nil
+			}
+
+			return []slog.Attr{slog.Int("code", ce.code), slog.String(attr.ErrorMessageKey, "replaced")}
+		}},
+	}
+
+	fields := e.NewErrorValue(fmt.Errorf("wrapped: %w", codeError{code: 7})).GetStructValue().GetFields()
+	assert.InDelta(t, 7, fields["code"].GetNumberValue(), 0)
+	assert.Equal(t, "wrapped: code 7", fields[attr.ErrorMessageKey].GetStringValue())
+
+	fields = e.NewErrorValue(errors.New("plain")).GetStructValue().GetFields()
+	assert.NotContains(t, fields, "code")
+}
+
+func TestEncoding_NewAny_unstructuredError(t *testing.T) {
+	v, ok := (&attr.Encoding{}).NewAny(errors.New("plain"))
+	assert.True(t, ok)
+	assert.Equal(t, attr.NewStringValue("plain"), v)
+}
+
+type temperature float64
+
+func (t temperature) String() string {
+	return strconv.FormatFloat(float64(t), 'f', 1, 64) + "°C"
+}
+
+type endpoint struct {
+	host string
+}
+
+func (e *endpoint) String() string {
+	return "https://" + e.host
+}
+
+type loop struct{}
+
+func (l loop) LogValue() slog.Value {
+	return slog.AnyValue(l)
+}
+
+type ipv4 [4]byte
+
+func (a ipv4) String() string {
+	return net.IP(a[:]).String()
+}
+
+func TestEncoding_NewAny_conversions(t *testing.T) {
+	e := attr.Encoding{}
+
+	tests := map[string]struct {
+		value any
+		want  *structpb.Value
+	}{
+		"text marshaler":      {net.IPv4(10, 0, 0, 1), attr.NewStringValue("10.0.0.1")},
+		"byte array stringer": {ipv4{10, 0, 0, 2}, attr.NewStringValue("10.0.0.2")},
+		"pointer stringer":    {endpoint{host: "example.com"}, attr.NewStringValue("https://example.com")},
+		"stringer number":     {temperature(21.5), attr.NewNumberValue(21.5)},
+		"big int":             {big.NewInt(42), attr.NewNumberValue(42)},
+		"log valuer":          {Password("secret"), attr.NewStringValue("<secret>")},
+		"nil pointer":         {(*endpoint)(nil), attr.NewNilValue()},
+		"log valuer too deep": {loop{}, attr.NewStringValue("LogValue called too many times on Value of type attr_test.loop")},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			v, ok := e.NewAny(tc.value)
+			assert.True(t, ok)
+			assert.Equal(t, tc.want.String(), v.String())
+		})
+	}
+}
+
+type money struct {
+	Cents    int64  `json:"cents"`
+	Currency string `json:"currency"`
+}
+
+func (m money) String() string {
+	return strconv.FormatInt(m.Cents, 10) + " " + m.Currency
+}
+
+func TestEncoding_NewAny_conversionsJSON(t *testing.T) {
+	e := attr.Encoding{}
+
+	// values whose JSON is a non-empty object are still mapped via JSON
+	v, ok := e.NewAny(money{Cents: 150, Currency: "EUR"})
+	assert.True(t, ok)
+	assert.Equal(t, "EUR", v.GetStructValue().GetFields()["currency"].GetStringValue())
+
+	v, ok = e.NewAny(url.URL{Scheme: "https", Host: "example.com"})
+	assert.True(t, ok)
+	assert.Equal(t, "example.com", v.GetStructValue().GetFields()["Host"].GetStringValue())
+}
+
+func TestEncoding_NewAny_conversionsPrecedence(t *testing.T) {
+	v, ok := (&attr.Encoding{Conversions: []attr.Conversion{attr.ConvertStringer}}).NewAny(net.IPv4(10, 0, 0, 1))
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.1", v.GetStringValue())
+
+	// without conversions, the JSON fallback is kept
+	v, ok = (&attr.Encoding{Conversions: []attr.Conversion{}}).NewAny(endpoint{host: "example.com"})
+	assert.True(t, ok)
+	assert.Empty(t, v.GetStructValue().GetFields())
+
+	v, ok = (&attr.Encoding{Conversions: []attr.Conversion{}}).NewAny(ipv4{10, 0, 0, 2})
+	assert.True(t, ok)
+	assert.Len(t, v.GetListValue().GetValues(), 4)
+}
+
+func TestInnermostStackTrace(t *testing.T) {
+	_, ok := attr.InnermostStackTrace(errors.New("plain"))
+	assert.False(t, ok)
+
+	inner := pkgerrors.New("inner")
+	outer := pkgerrors.Wrap(fmt.Errorf("middle: %w", inner), "outer")
+
+	pcs, ok := attr.InnermostStackTrace(outer)
+	assert.True(t, ok)
+
+	want := inner.(interface{ StackTrace() pkgerrors.StackTrace }).StackTrace()
+	assert.Len(t, pcs, len(want))
+
+	frames := attr.Frames(pcs)
+	assert.NotEmpty(t, frames)
+	assert.Equal(t, "m4o.io/gslog/internal/attr_test.TestInnermostStackTrace", frames[0].Function)
+}
@@ -0,0 +1,200 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attr
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+
+	spb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// Conversion identifies an interface honored when mapping values that are
+// neither registered with an Encoder, proto.Message values nor errors.
+type Conversion int
+
+const (
+	// ConvertLogValuer maps slog.LogValuer values to the values returned by
+	// their LogValue methods, resolved as slog.Value's Resolve does.
+	ConvertLogValuer Conversion = iota
+	// ConvertJSONMarshaler maps json.Marshaler values via their JSON
+	// representation.
+	ConvertJSONMarshaler
+	// ConvertTextMarshaler maps encoding.TextMarshaler values to the strings
+	// returned by their MarshalText methods, if the values cannot otherwise
+	// be mapped, see Encoding.NewAny.
+	ConvertTextMarshaler
+	// ConvertStringer maps fmt.Stringer values to the strings returned by
+	// their String methods, if the values cannot otherwise be mapped, see
+	// Encoding.NewAny.
+	ConvertStringer
+)
+
+// DefaultConversions is the precedence of the conversions used when the
+// Encoding's Conversions is nil.
+//
+//nolint:gochecknoglobals
+var DefaultConversions = []Conversion{
+	ConvertLogValuer,
+	ConvertJSONMarshaler,
+	ConvertTextMarshaler,
+	ConvertStringer,
+}
+
+// maxLogValuerDepth is the maximum number of slog.LogValuer values resolved
+// to map a value, guarding against LogValuers whose values are, or contain,
+// LogValuers without end.
+const maxLogValuerDepth = 32
+
+// resolve resolves the slog.LogValuer held by v, unless depth exceeds
+// maxLogValuerDepth, in which case an error string is returned instead, as
+// slog.Value's Resolve does.
+func resolve(v slog.Value, depth int) slog.Value {
+	if depth > maxLogValuerDepth {
+		return slog.StringValue(fmt.Sprintf("LogValue called too many times on Value of type %T", v.Any()))
+	}
+
+	return v.Resolve()
+}
+
+func (e *Encoding) conversions() []Conversion {
+	if e.Conversions == nil {
+		return DefaultConversions
+	}
+
+	return e.Conversions
+}
+
+// convert maps the value using the first of the Encoding's ConvertLogValuer
+// and ConvertJSONMarshaler conversions it supports, if any.  The interfaces
+// are also honored when implemented by the pointer to the value.  Depth is
+// the number of slog.LogValuer values resolved to reach the value.
+func (e *Encoding) convert(a any, depth int) (*spb.Value, bool) {
+	if a == nil || isNilPointer(a) {
+		return nil, false
+	}
+
+	for _, c := range e.conversions() {
+		switch c {
+		case ConvertLogValuer:
+			if lv, ok := as[slog.LogValuer](a); ok {
+				return e.valToStruct(resolve(slog.AnyValue(lv), depth+1), depth+1)
+			}
+		case ConvertJSONMarshaler:
+			if _, ok := as[json.Marshaler](a); ok {
+				return e.AsJSON(a)
+			}
+		case ConvertTextMarshaler, ConvertStringer:
+		}
+	}
+
+	return nil, false
+}
+
+// convertText maps the value to a string using the first of the Encoding's
+// ConvertTextMarshaler and ConvertStringer conversions it supports, if any.
+func (e *Encoding) convertText(a any) (*spb.Value, bool) {
+	if a == nil || isNilPointer(a) {
+		return nil, false
+	}
+
+	for _, c := range e.conversions() {
+		switch c {
+		case ConvertTextMarshaler:
+			if tm, ok := as[encoding.TextMarshaler](a); ok {
+				if b, err := tm.MarshalText(); err == nil {
+					return NewStringValue(string(b)), true
+				}
+			}
+		case ConvertStringer:
+			if s, ok := as[fmt.Stringer](a); ok {
+				return NewStringValue(s.String()), true
+			}
+		case ConvertLogValuer, ConvertJSONMarshaler:
+		}
+	}
+
+	return nil, false
+}
+
+// fromJSONOrText maps the value via JSON unless that fails, or the value is a
+// byte slice or array or its JSON is an empty object, in which case it is
+// mapped using convertText if possible.
+func (e *Encoding) fromJSONOrText(a any) (*spb.Value, bool) {
+	if isBytes(a) {
+		if v, ok := e.convertText(a); ok {
+			return v, true
+		}
+	}
+
+	v, ok := e.AsJSON(a)
+	if ok && !isEmptyStruct(v) {
+		return v, true
+	}
+
+	if tv, tok := e.convertText(a); tok {
+		return tv, true
+	}
+
+	return v, ok
+}
+
+func isBytes(a any) bool {
+	t := reflect.TypeOf(a)
+	if t == nil {
+		return false
+	}
+
+	k := t.Kind()
+
+	return (k == reflect.Slice || k == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+func isEmptyStruct(v *spb.Value) bool {
+	s, ok := v.GetKind().(*spb.Value_StructValue)
+
+	return ok && len(s.StructValue.GetFields()) == 0
+}
+
+// as returns the value as a T if either it, or a pointer to a copy of it,
+// implements T.
+func as[T any](a any) (T, bool) {
+	if t, ok := a.(T); ok {
+		return t, true
+	}
+
+	var zero T
+
+	rt := reflect.TypeOf(a)
+	if rt.Kind() == reflect.Pointer || !reflect.PointerTo(rt).Implements(reflect.TypeOf(&zero).Elem()) {
+		return zero, false
+	}
+
+	p := reflect.New(rt)
+	p.Elem().Set(reflect.ValueOf(a))
+
+	t, ok := p.Interface().(T)
+
+	return t, ok
+}
+
+func isNilPointer(a any) bool {
+	v := reflect.ValueOf(a)
+
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
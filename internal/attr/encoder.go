@@ -0,0 +1,80 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attr
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	spb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// Encoder creates the spb.Value equivalent of a value of the type it was
+// registered for.  A nil spb.Value causes the value to be mapped as if no
+// Encoder was registered.
+type Encoder func(a any) *spb.Value
+
+//nolint:gochecknoglobals
+var (
+	encodersMu sync.Mutex
+	// encoders holds a map[reflect.Type]Encoder that is replaced, never
+	// modified, when an Encoder is registered so that it can be read
+	// without locking.
+	encoders atomic.Pointer[map[reflect.Type]Encoder]
+)
+
+// RegisterEncoder registers the Encoder used for the values whose dynamic type
+// is t, replacing any previously registered for t.  A nil Encoder removes the
+// registration.
+func RegisterEncoder(t reflect.Type, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	var current map[reflect.Type]Encoder
+	if p := encoders.Load(); p != nil {
+		current = *p
+	}
+
+	updated := make(map[reflect.Type]Encoder, len(current)+1)
+	for k, v := range current {
+		updated[k] = v
+	}
+
+	if encoder == nil {
+		delete(updated, t)
+	} else {
+		updated[t] = encoder
+	}
+
+	encoders.Store(&updated)
+}
+
+// encode maps the value using the Encoder registered for its type, if any.
+func encode(a any) (*spb.Value, bool) {
+	p := encoders.Load()
+	if p == nil || a == nil {
+		return nil, false
+	}
+
+	encoder, ok := (*p)[reflect.TypeOf(a)]
+	if !ok {
+		return nil, false
+	}
+
+	v := encoder(a)
+
+	return v, v != nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attr
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strconv"
+
+	pkgerrors "github.com/pkg/errors"
+	spb "google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// ErrorMessageKey is the key of an error's Error() string.
+	ErrorMessageKey = "message"
+	// ErrorTypeKey is the key of an error's Go type.
+	ErrorTypeKey = "type"
+	// ErrorChainKey is the key of the errors wrapped by an error.
+	ErrorChainKey = "chain"
+	// ErrorErrorsKey is the key of the errors joined by an error, e.g. by
+	// errors.Join.
+	ErrorErrorsKey = "errors"
+	// ErrorStackTraceKey is the key of the stack trace carried by an error.
+	ErrorStackTraceKey = "stack_trace"
+
+	// maxErrorDepth bounds the errors walked, guarding against cyclic
+	// chains.
+	maxErrorDepth = 32
+)
+
+// ErrorFields returns the attributes added to the struct an error is mapped
+// to, e.g. the code of a gRPC status error.  It returns nil for the errors it
+// does not know about.
+type ErrorFields func(err error) []slog.Attr
+
+// stackTracer is implemented by the errors of github.com/pkg/errors that
+// carry the stack trace of where they were created.
+type stackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// NewErrorValue creates a struct spb.Value holding the error's message and Go
+// type, the chain of the errors it wraps, each with their message and type,
+// and the stack trace of the innermost error of the chain that carries one.
+// Errors joining several errors, e.g. those created by errors.Join, end the
+// chain and hold the errors they join, each mapped as by NewErrorValue.
+//
+// The Encoding's ErrorFields add their attributes to the struct, without
+// replacing the fields above.
+func (e *Encoding) NewErrorValue(err error) *spb.Value {
+	return e.newErrorValue(err, 0)
+}
+
+func (e *Encoding) newErrorValue(err error, depth int) *spb.Value {
+	s := &spb.Struct{Fields: make(map[string]*spb.Value)}
+
+	for _, fields := range e.ErrorFields {
+		for _, a := range fields(err) {
+			e.DecorateWith(s, a)
+		}
+	}
+
+	e.decorateError(s.Fields, err, depth)
+
+	var chain []*spb.Value
+
+	for wrapped := errors.Unwrap(err); wrapped != nil && depth < maxErrorDepth; wrapped = errors.Unwrap(wrapped) {
+		depth++
+
+		link := &spb.Struct{Fields: make(map[string]*spb.Value)}
+		e.decorateError(link.Fields, wrapped, depth)
+		chain = append(chain, &spb.Value{Kind: &spb.Value_StructValue{StructValue: link}})
+	}
+
+	if len(chain) > 0 {
+		s.Fields[ErrorChainKey] = &spb.Value{Kind: &spb.Value_ListValue{ListValue: &spb.ListValue{Values: chain}}}
+	}
+
+	if pcs, ok := InnermostStackTrace(err); ok {
+		s.Fields[ErrorStackTraceKey] = newStackTraceValue(pcs)
+	}
+
+	return &spb.Value{Kind: &spb.Value_StructValue{StructValue: s}}
+}
+
+// decorateError adds the error's message and type, and the errors it joins,
+// if any, to the fields.
+func (e *Encoding) decorateError(fields map[string]*spb.Value, err error, depth int) {
+	fields[ErrorMessageKey] = NewStringValue(err.Error())
+	fields[ErrorTypeKey] = NewStringValue(reflect.TypeOf(err).String())
+
+	joined, ok := err.(interface{ Unwrap() []error }) //nolint:errorlint
+	if !ok || depth >= maxErrorDepth {
+		return
+	}
+
+	var errs []*spb.Value
+
+	for _, j := range joined.Unwrap() {
+		if j != nil {
+			errs = append(errs, e.newErrorValue(j, depth+1))
+		}
+	}
+
+	fields[ErrorErrorsKey] = &spb.Value{Kind: &spb.Value_ListValue{ListValue: &spb.ListValue{Values: errs}}}
+}
+
+// InnermostStackTrace returns the program counters of the stack trace carried
+// by the deepest error of err's chain that carries one, since that is where
+// the error originated.  Errors carry stack traces by implementing
+// StackTrace(), as the errors of github.com/pkg/errors do.
+func InnermostStackTrace(err error) ([]uintptr, bool) {
+	var found stackTracer
+
+	for depth := 0; err != nil && depth <= maxErrorDepth; depth++ {
+		if st, ok := err.(stackTracer); ok { //nolint:errorlint
+			found = st
+		}
+
+		err = errors.Unwrap(err)
+	}
+
+	if found == nil {
+		return nil, false
+	}
+
+	st := found.StackTrace()
+
+	pcs := make([]uintptr, 0, len(st))
+	for _, f := range st {
+		pcs = append(pcs, uintptr(f))
+	}
+
+	return pcs, true
+}
+
+// Frames returns the frames of the program counters, e.g. returned by
+// InnermostStackTrace or runtime.Callers, skipping those whose function is
+// unknown.
+func Frames(pcs []uintptr) []runtime.Frame {
+	frames := make([]runtime.Frame, 0, len(pcs))
+	cf := runtime.CallersFrames(pcs)
+
+	for {
+		f, more := cf.Next()
+		if f.Function != "" {
+			frames = append(frames, f)
+		}
+
+		if !more {
+			return frames
+		}
+	}
+}
+
+// newStackTraceValue creates a list spb.Value of the frames of the stack
+// trace, each formatted as "function (file:line)".
+func newStackTraceValue(pcs []uintptr) *spb.Value {
+	frames := Frames(pcs)
+
+	values := make([]*spb.Value, 0, len(frames))
+	for _, f := range frames {
+		values = append(values, NewStringValue(f.Function+" ("+f.File+":"+strconv.Itoa(f.Line)+")"))
+	}
+
+	return &spb.Value{Kind: &spb.Value_ListValue{ListValue: &spb.ListValue{Values: values}}}
+}
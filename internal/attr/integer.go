@@ -0,0 +1,149 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attr
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	spb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// MaxSafeInteger is the largest integer that can be exactly represented by a
+// float64, and thus by a number spb.Value.
+const MaxSafeInteger = 1<<53 - 1
+
+// NewIntValue creates the spb.Value equivalent of the supplied int64, which
+// is a decimal string if out of the safe range and the Encoding's
+// LosslessIntegers is set.
+func (e *Encoding) NewIntValue(i int64) *spb.Value {
+	if e.LosslessIntegers && (i > MaxSafeInteger || i < -MaxSafeInteger) {
+		return NewStringValue(strconv.FormatInt(i, 10))
+	}
+
+	return NewNumberValue(float64(i))
+}
+
+// NewUintValue creates the spb.Value equivalent of the supplied uint64, which
+// is a decimal string if out of the safe range and the Encoding's
+// LosslessIntegers is set.
+func (e *Encoding) NewUintValue(u uint64) *spb.Value {
+	if e.LosslessIntegers && u > MaxSafeInteger {
+		return NewStringValue(strconv.FormatUint(u, 10))
+	}
+
+	return NewNumberValue(float64(u))
+}
+
+// newValue maps the values that spb.NewValue can, taking care of the
+// integers, including those nested in maps and slices, if the Encoding's
+// LosslessIntegers is set.
+//
+//nolint:cyclop
+func (e *Encoding) newValue(a any) (*spb.Value, bool) {
+	if !e.LosslessIntegers {
+		nv, err := spb.NewValue(a)
+
+		return nv, err == nil
+	}
+
+	switch v := a.(type) {
+	case int:
+		return e.NewIntValue(int64(v)), true
+	case int64:
+		return e.NewIntValue(v), true
+	case uint:
+		return e.NewUintValue(uint64(v)), true
+	case uint64:
+		return e.NewUintValue(v), true
+	case map[string]any:
+		s := &spb.Struct{Fields: make(map[string]*spb.Value, len(v))}
+
+		for k, val := range v {
+			nv, ok := e.newValue(val)
+			if !ok {
+				return nil, false
+			}
+
+			s.Fields[k] = nv
+		}
+
+		return &spb.Value{Kind: &spb.Value_StructValue{StructValue: s}}, true
+	case []any:
+		l := &spb.ListValue{Values: make([]*spb.Value, 0, len(v))}
+
+		for _, val := range v {
+			nv, ok := e.newValue(val)
+			if !ok {
+				return nil, false
+			}
+
+			l.Values = append(l.Values, nv)
+		}
+
+		return &spb.Value{Kind: &spb.Value_ListValue{ListValue: l}}, true
+	default:
+		nv, err := spb.NewValue(a)
+
+		return nv, err == nil
+	}
+}
+
+// fromJSON maps a JSON object decoded with json.Number numbers, keeping the
+// integers out of the safe range as decimal strings.
+func (e *Encoding) fromJSON(a any) *spb.Value {
+	switch v := a.(type) {
+	case json.Number:
+		return e.newNumberValue(v)
+	case map[string]any:
+		s := &spb.Struct{Fields: make(map[string]*spb.Value, len(v))}
+		for k, val := range v {
+			s.Fields[k] = e.fromJSON(val)
+		}
+
+		return &spb.Value{Kind: &spb.Value_StructValue{StructValue: s}}
+	case []any:
+		l := &spb.ListValue{Values: make([]*spb.Value, 0, len(v))}
+		for _, val := range v {
+			l.Values = append(l.Values, e.fromJSON(val))
+		}
+
+		return &spb.Value{Kind: &spb.Value_ListValue{ListValue: l}}
+	default:
+		nv, _ := spb.NewValue(a)
+
+		return nv
+	}
+}
+
+func (e *Encoding) newNumberValue(n json.Number) *spb.Value {
+	if !strings.ContainsAny(n.String(), ".eE") {
+		if i, err := n.Int64(); err == nil {
+			return e.NewIntValue(i)
+		}
+
+		if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+			return e.NewUintValue(u)
+		}
+
+		// beyond 64 bits
+		return NewStringValue(n.String())
+	}
+
+	f, _ := n.Float64()
+
+	return NewNumberValue(f)
+}
@@ -23,8 +23,11 @@ import (
 	"context"
 	"log/slog"
 	"math"
+	"time"
 
 	"cloud.google.com/go/logging"
+
+	"m4o.io/gslog/internal/attr"
 )
 
 const (
@@ -35,6 +38,48 @@ const (
 // and group path is provided, in case they are needed by the augmentor.
 type EntryAugmentor func(ctx context.Context, e *logging.Entry, groups []string)
 
+// NamedEntryAugmentor augments an instance of logging.Entry, returning an
+// error if it could not.  The name identifies the augmentor in the errors
+// reported.
+type NamedEntryAugmentor struct {
+	Name    string
+	Augment func(ctx context.Context, e *logging.Entry, groups []string) error
+}
+
+// RouteMatcher reports whether an instance of logging.Entry is to be routed.
+// The current context and group path is provided, in case they are needed by
+// the matcher.
+type RouteMatcher func(ctx context.Context, e *logging.Entry, groups []string) bool
+
+// Logger mirrors gslog.Logger, which cannot be referenced here without
+// introducing an import cycle.
+type Logger interface {
+	Log(e logging.Entry)
+	LogSync(ctx context.Context, e logging.Entry) error
+	Flush() error
+}
+
+// Route directs the entries that match to a Logger.
+type Route struct {
+	Match  RouteMatcher
+	Logger Logger
+}
+
+// SyncPolicy reports whether the entry for a slog.Record is to be logged
+// synchronously.
+type SyncPolicy func(ctx context.Context, r slog.Record) bool
+
+// ErrorHandler is called when an entry could not be logged.
+type ErrorHandler func(err error, r slog.Record, e logging.Entry)
+
+// TruncationStrategy identifies how entries exceeding the size limit are
+// reduced.
+type TruncationStrategy int
+
+// SampleFunc reports whether a slog.Record is to be logged and, if so, how
+// many similar records were dropped since the last one that was logged.
+type SampleFunc func(r slog.Record) (keep bool, dropped uint64)
+
 // Options holds information needed to construct an instance of GcpHandler.
 type Options struct {
 	ExplicitLogLevel slog.Leveler
@@ -43,6 +88,61 @@ type Options struct {
 
 	EntryAugmentors []EntryAugmentor
 
+	// NamedEntryAugmentors are applied, in order, after the EntryAugmentors.
+	NamedEntryAugmentors []NamedEntryAugmentor
+
+	// Routes are tried in order, the first whose RouteMatcher matches the
+	// entry determines the Logger it is logged to.
+	Routes []Route
+
+	// SyncPolicy decides which records are logged synchronously.  If nil,
+	// records of critical level, or higher, are.
+	SyncPolicy SyncPolicy
+
+	// OnError is called when an entry could not be logged.  If nil, the
+	// error is written to os.Stderr.
+	OnError ErrorHandler
+
+	// ReturnErrors causes the handler's Handle method to return the errors
+	// encountered when logging entries.
+	ReturnErrors bool
+
+	// Sample decides which records are logged.  If nil, all are.
+	Sample SampleFunc
+
+	// DedupWindow is the window within which identical records are
+	// collapsed.  Zero disables the deduplication.
+	DedupWindow time.Duration
+
+	// CallerLevels maps the prefixes of the functions logging records to
+	// the levels overriding the handler's for those records.
+	CallerLevels map[string]slog.Level
+
+	// BufferSize is the number of entries held by the buffers attached to
+	// contexts.  Zero, or less, uses the default size.
+	BufferSize int
+
+	// BufferTrigger is the level at, or above, which the entries held by the
+	// buffer attached to a context are logged.
+	BufferTrigger slog.Level
+
+	// Encoding configures how attribute values are mapped to their
+	// structpb.Value equivalents.
+	Encoding attr.Encoding
+
+	// SizeLimit is the maximum size, in bytes, of an entry's payload and
+	// labels.  Zero disables the size enforcement.
+	SizeLimit int
+
+	// TruncationStrategy is how entries exceeding SizeLimit are reduced.
+	TruncationStrategy TruncationStrategy
+
+	// ErrorReportingService and ErrorReportingVersion are the service
+	// context of error records written in the Cloud Error Reporting format.
+	// An empty ErrorReportingService disables the format.
+	ErrorReportingService string
+	ErrorReportingVersion string
+
 	// AddSource causes the handler to compute the source code position
 	// of the log statement and add a SourceKey attribute to the output.
 	AddSource bool
@@ -93,10 +193,23 @@ func ApplyOptions(options ...OptionProcessor) *Options {
 		ExplicitLogLevel: levelUnknown,
 		DefaultLogLevel:  levelUnknown,
 
-		EntryAugmentors: nil,
-		AddSource:       false,
-		Level:           slog.LevelInfo,
-		ReplaceAttr:     nil,
+		EntryAugmentors:      nil,
+		NamedEntryAugmentors: nil,
+		Routes:               nil,
+		SyncPolicy:           nil,
+		OnError:              nil,
+		ReturnErrors:         false,
+		Sample:               nil,
+		DedupWindow:          0,
+		CallerLevels:         nil,
+		BufferSize:           0,
+		BufferTrigger:        slog.LevelError,
+		Encoding:             attr.Encoding{},
+		SizeLimit:            0,
+		TruncationStrategy:   0,
+		AddSource:            false,
+		Level:                slog.LevelInfo,
+		ReplaceAttr:          nil,
 	}
 	for _, opt := range options {
 		opt(opts)
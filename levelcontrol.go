@@ -0,0 +1,348 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var levelNames = map[string]slog.Level{
+	"DEBUG":     slog.LevelDebug,
+	"INFO":      slog.LevelInfo,
+	"NOTICE":    LevelNotice,
+	"WARN":      slog.LevelWarn,
+	"WARNING":   slog.LevelWarn,
+	"ERROR":     slog.LevelError,
+	"CRITICAL":  LevelCritical,
+	"ALERT":     LevelAlert,
+	"EMERGENCY": LevelEmergency,
+}
+
+// ParseLevel parses a level, either an integer, one of the slog level names,
+// e.g. "DEBUG" or "WARN+1", or one of the names of the levels defined by this
+// package, e.g. "NOTICE" or "CRITICAL".  Names are case-insensitive.
+func ParseLevel(s string) (slog.Level, error) {
+	s = strings.TrimSpace(s)
+
+	if lvl, err := strconv.Atoi(s); err == nil {
+		return slog.Level(lvl), nil
+	}
+
+	if lvl, ok := levelNames[strings.ToUpper(s)]; ok {
+		return lvl, nil
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(s)); err != nil {
+		return 0, errors.Errorf("unknown level %q", s)
+	}
+
+	return lvl, nil
+}
+
+// LevelController is a slog.Leveler, backed by a slog.LevelVar, whose level
+// can be changed at runtime: from a watched file, see WatchFile, over HTTP,
+// see ServeHTTP, or programmatically, see Set.  A level can be set for a
+// limited time, after which the controller reverts to its default level.
+//
+// Each change of level is logged at LevelNotice.
+type LevelController struct {
+	level        slog.LevelVar
+	defaultLevel slog.Level
+
+	mu      sync.Mutex
+	logger  *slog.Logger
+	timer   *time.Timer
+	expires time.Time
+	// generation is bumped whenever the level is set, so that an expiry
+	// timer can tell whether the level was set again since it was started.
+	generation uint64
+}
+
+var (
+	_ slog.Leveler = (*LevelController)(nil)
+	_ http.Handler = (*LevelController)(nil)
+)
+
+// NewLevelController creates a LevelController whose level is initially the
+// default level.  Use it as the handler's leveler via WithLogLeveler.
+func NewLevelController(defaultLevel slog.Level) *LevelController {
+	//nolint:exhaustruct
+	c := &LevelController{defaultLevel: defaultLevel}
+	c.level.Set(defaultLevel)
+
+	return c
+}
+
+// Level returns the controller's current level.
+func (c *LevelController) Level() slog.Level {
+	return c.level.Level()
+}
+
+// SetLogger sets the logger used to log the changes of level.  By default,
+// slog.Default() is used.
+func (c *LevelController) SetLogger(logger *slog.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logger = logger
+}
+
+// Set sets the controller's level.  If ttl is positive, the controller
+// reverts to its default level once ttl has elapsed.
+func (c *LevelController) Set(level slog.Level, ttl time.Duration) {
+	c.set(level, ttl, "api")
+}
+
+// Reset reverts the controller to its default level.
+func (c *LevelController) Reset() {
+	c.set(c.defaultLevel, 0, "api")
+}
+
+func (c *LevelController) set(level slog.Level, ttl time.Duration, source string) {
+	c.change(0, level, ttl, source)
+}
+
+// change sets the controller's level, as set does.  If expected is not zero,
+// the level is only changed if expected is still the controller's generation,
+// i.e. the level was not set again since the expiry timer was started.
+func (c *LevelController) change(expected uint64, level slog.Level, ttl time.Duration, source string) {
+	c.mu.Lock()
+
+	if expected != 0 && c.generation != expected {
+		c.mu.Unlock()
+
+		return
+	}
+
+	c.generation++
+
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+
+	c.expires = time.Time{}
+
+	if ttl > 0 {
+		c.expires = time.Now().Add(ttl)
+
+		generation := c.generation
+		c.timer = time.AfterFunc(ttl, func() { c.expire(generation) })
+	}
+
+	previous := c.level.Level()
+	c.level.Set(level)
+
+	expires := c.expires
+
+	c.mu.Unlock()
+
+	r := slog.NewRecord(time.Now(), LevelNotice, "Log level changed.", 0)
+	r.AddAttrs(slog.String("level", levelString(level)), slog.String("previous", levelString(previous)), slog.String("source", source))
+
+	if !expires.IsZero() {
+		r.AddAttrs(slog.Time("expires", expires))
+	}
+
+	// the change is logged regardless of the level, which may well be above
+	// LevelNotice
+	_ = c.log().Handler().Handle(context.Background(), r)
+}
+
+// expire reverts to the default level, unless the level was set again since
+// the timer of the generation was started.
+func (c *LevelController) expire(generation uint64) {
+	c.change(generation, c.defaultLevel, 0, "expiry")
+}
+
+func (c *LevelController) log() *slog.Logger {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.logger != nil {
+		return c.logger
+	}
+
+	return slog.Default()
+}
+
+// WatchFile sets the controller's level from the contents of the file, e.g.
+// a file of a mounted Kubernetes ConfigMap, parsed using ParseLevel.  The
+// file is then checked for changes every interval until the context is done.
+// An error is returned if the file cannot be initially read or parsed; later
+// failures are logged at slog.LevelWarn and the level left unchanged.
+func (c *LevelController) WatchFile(ctx context.Context, path string, interval time.Duration) error {
+	if interval <= 0 {
+		panic("interval must be positive")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "unable to read level file")
+	}
+
+	level, err := ParseLevel(string(content))
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse level file %s", path)
+	}
+
+	c.set(level, 0, "file")
+
+	go c.watch(ctx, path, interval, content)
+
+	return nil
+}
+
+func (c *LevelController) watch(ctx context.Context, path string, interval time.Duration, last []byte) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			c.log().Warn("Unable to read level file.", slog.String("path", path), slog.Any("error", err))
+
+			continue
+		}
+
+		if bytes.Equal(content, last) {
+			continue
+		}
+
+		last = content
+
+		level, err := ParseLevel(string(content))
+		if err != nil {
+			c.log().Warn("Unable to parse level file.", slog.String("path", path), slog.Any("error", err))
+
+			continue
+		}
+
+		c.set(level, 0, "file")
+	}
+}
+
+// levelState is the JSON representation of a LevelController's state.
+type levelState struct {
+	Level   string `json:"level"`
+	Default string `json:"default,omitempty"`
+	TTL     string `json:"ttl,omitempty"`
+	Expires string `json:"expires,omitempty"`
+}
+
+// ServeHTTP exposes the controller's level over HTTP.  A GET returns the
+// current and default levels, and when the current level expires, as a JSON
+// object, e.g.
+//
+//	{"level":"DEBUG","default":"INFO","expires":"2024-01-02T03:04:05Z"}
+//
+// A PUT sets the level from a JSON object with a "level" and an optional
+// "ttl", parsed using time.ParseDuration, e.g.
+//
+//	{"level":"DEBUG","ttl":"15m"}
+//
+// A DELETE reverts to the default level.  PUT and DELETE respond as GET does.
+func (c *LevelController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var s levelState
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		level, err := ParseLevel(s.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		var ttl time.Duration
+		if s.TTL != "" {
+			if ttl, err = time.ParseDuration(s.TTL); err != nil || ttl < 0 {
+				http.Error(w, "invalid ttl: "+s.TTL, http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		c.set(level, ttl, "http")
+	case http.MethodDelete:
+		c.set(c.defaultLevel, 0, "http")
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c.state())
+}
+
+func (c *LevelController) state() levelState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	//nolint:exhaustruct
+	s := levelState{
+		Level:   levelString(c.level.Level()),
+		Default: levelString(c.defaultLevel),
+	}
+
+	if !c.expires.IsZero() {
+		s.Expires = c.expires.UTC().Format(time.RFC3339)
+	}
+
+	return s
+}
+
+// levelString returns the name of the level, using the names of the levels
+// defined by this package.
+func levelString(level slog.Level) string {
+	switch level {
+	case LevelNotice:
+		return "NOTICE"
+	case LevelCritical:
+		return "CRITICAL"
+	case LevelAlert:
+		return "ALERT"
+	case LevelEmergency:
+		return "EMERGENCY"
+	default:
+		return level.String()
+	}
+}
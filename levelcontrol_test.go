@@ -0,0 +1,211 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"DEBUG":     slog.LevelDebug,
+		"info":      slog.LevelInfo,
+		"Notice":    gslog.LevelNotice,
+		"WARN+1":    slog.LevelWarn + 1,
+		"warning":   slog.LevelWarn,
+		"ERROR":     slog.LevelError,
+		"CRITICAL":  gslog.LevelCritical,
+		"ALERT":     gslog.LevelAlert,
+		"EMERGENCY": gslog.LevelEmergency,
+		" -4\n":     slog.LevelDebug,
+	}
+
+	for s, want := range tests {
+		lvl, err := gslog.ParseLevel(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, lvl, s)
+	}
+
+	_, err := gslog.ParseLevel("LOUD")
+	assert.Error(t, err)
+}
+
+func newControlledLogger(c *gslog.LevelController) *syncCollector {
+	sc := &syncCollector{}
+	c.SetLogger(slog.New(gslog.NewGcpHandler(sc, gslog.WithLogLeveler(c))))
+
+	return sc
+}
+
+func (c *syncCollector) last() logging.Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.entries[len(c.entries)-1]
+}
+
+func TestLevelController_Set(t *testing.T) {
+	c := gslog.NewLevelController(slog.LevelInfo)
+	sc := newControlledLogger(c)
+
+	assert.Equal(t, slog.LevelInfo, c.Level())
+
+	c.Set(slog.LevelError, 0)
+	assert.Equal(t, slog.LevelError, c.Level())
+
+	// the change is logged even though the level is above LevelNotice
+	e := sc.last()
+	fields := e.Payload.(*structpb.Struct).GetFields()
+
+	assert.Equal(t, logging.Notice, e.Severity)
+	assert.Equal(t, "ERROR", fields["level"].GetStringValue())
+	assert.Equal(t, "INFO", fields["previous"].GetStringValue())
+
+	c.Reset()
+	assert.Equal(t, slog.LevelInfo, c.Level())
+	assert.Equal(t, 2, sc.len())
+}
+
+func TestLevelController_Set_expiry(t *testing.T) {
+	c := gslog.NewLevelController(slog.LevelInfo)
+	sc := newControlledLogger(c)
+
+	c.Set(slog.LevelDebug, 10*time.Millisecond)
+	assert.Equal(t, slog.LevelDebug, c.Level())
+	assert.Contains(t, sc.last().Payload.(*structpb.Struct).GetFields(), "expires")
+
+	assert.Eventually(t, func() bool { return c.Level() == slog.LevelInfo }, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool { return sc.len() == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, "expiry", sc.last().Payload.(*structpb.Struct).GetFields()["source"].GetStringValue())
+}
+
+func TestLevelController_Set_expiryCancelled(t *testing.T) {
+	c := gslog.NewLevelController(slog.LevelInfo)
+	newControlledLogger(c)
+
+	c.Set(slog.LevelDebug, 10*time.Millisecond)
+	c.Set(slog.LevelWarn, 0)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, slog.LevelWarn, c.Level())
+}
+
+func serveLevel(c *gslog.LevelController, method, body string) (int, map[string]string) {
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(method, "/level", strings.NewReader(body)))
+
+	var state map[string]string
+	_ = json.Unmarshal(w.Body.Bytes(), &state)
+
+	return w.Code, state
+}
+
+func TestLevelController_ServeHTTP(t *testing.T) {
+	c := gslog.NewLevelController(slog.LevelInfo)
+	newControlledLogger(c)
+
+	code, state := serveLevel(c, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]string{"level": "INFO", "default": "INFO"}, state)
+
+	code, state = serveLevel(c, http.MethodPut, `{"level":"debug","ttl":"1h"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "DEBUG", state["level"])
+	assert.NotEmpty(t, state["expires"])
+	assert.Equal(t, slog.LevelDebug, c.Level())
+
+	code, state = serveLevel(c, http.MethodDelete, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]string{"level": "INFO", "default": "INFO"}, state)
+
+	code, _ = serveLevel(c, http.MethodPut, `{"level":"LOUD"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = serveLevel(c, http.MethodPut, `{"level":"DEBUG","ttl":"soon"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = serveLevel(c, http.MethodPut, `level=DEBUG`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = serveLevel(c, http.MethodPost, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	assert.Equal(t, slog.LevelInfo, c.Level())
+}
+
+func TestLevelController_WatchFile(t *testing.T) {
+	c := gslog.NewLevelController(slog.LevelInfo)
+	newControlledLogger(c)
+
+	path := filepath.Join(t.TempDir(), "level")
+	assert.NoError(t, os.WriteFile(path, []byte("WARN\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.NoError(t, c.WatchFile(ctx, path, time.Millisecond))
+	assert.Equal(t, slog.LevelWarn, c.Level())
+
+	assert.NoError(t, os.WriteFile(path, []byte("DEBUG\n"), 0o600))
+	assert.Eventually(t, func() bool { return c.Level() == slog.LevelDebug }, time.Second, time.Millisecond)
+
+	// an invalid level leaves the level unchanged
+	assert.NoError(t, os.WriteFile(path, []byte("LOUD\n"), 0o600))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, slog.LevelDebug, c.Level())
+}
+
+func TestLevelController_WatchFile_invalid(t *testing.T) {
+	c := gslog.NewLevelController(slog.LevelInfo)
+
+	assert.Error(t, c.WatchFile(context.Background(), filepath.Join(t.TempDir(), "missing"), time.Second))
+
+	path := filepath.Join(t.TempDir(), "level")
+	assert.NoError(t, os.WriteFile(path, []byte("LOUD"), 0o600))
+	assert.Error(t, c.WatchFile(context.Background(), path, time.Second))
+
+	assert.Panics(t, func() {
+		_ = c.WatchFile(context.Background(), path, 0)
+	})
+}
+
+func TestLevelController_Set_expiryRace(t *testing.T) {
+	c := gslog.NewLevelController(slog.LevelInfo)
+	newControlledLogger(c)
+
+	for i := 0; i < 100; i++ {
+		c.Set(slog.LevelDebug, time.Nanosecond)
+		c.Set(slog.LevelWarn, 0)
+	}
+
+	// a stale expiry never overwrites a later level
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, slog.LevelWarn, c.Level())
+}
@@ -16,8 +16,20 @@ package gslog
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"cloud.google.com/go/logging"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	spb "google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog/internal/attr"
 )
 
 // Logger is wraps the set of methods that are used when interacting with a
@@ -74,3 +86,227 @@ func (fn LoggerFunc) LogSync(_ context.Context, e logging.Entry) error {
 func (fn LoggerFunc) Flush() error {
 	return nil
 }
+
+// JSONLogger is a Logger that renders each logging.Entry as a single line of
+// JSON written to an io.Writer, typically os.Stdout.  The output follows the
+// Google Cloud structured logging conventions, so that the logging agents
+// running on Cloud Run, GKE, Cloud Functions and App Engine ingest the lines
+// as if they had been written through the Cloud Logging API.
+//
+// The fields of a *spb.Struct payload are written at the root of the JSON
+// object.  The Entry's Severity, Timestamp, Trace, SpanID, TraceSampled,
+// Labels, SourceLocation, HTTPRequest, Operation and InsertID fields are
+// mapped onto their corresponding special fields, e.g.
+// "logging.googleapis.com/trace", which take precedence over any payload
+// fields with the same key.
+//
+// See https://cloud.google.com/logging/docs/structured-logging.
+type JSONLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ Logger = (*JSONLogger)(nil)
+
+// NewJSONLogger creates a JSONLogger that writes to w.  Writes to w are
+// serialized, so it is safe to share a JSONLogger between handlers.
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	if w == nil {
+		panic("writer is nil")
+	}
+
+	return &JSONLogger{w: w}
+}
+
+// Log implements Log.Log.  Errors writing the entry are dropped.
+func (l *JSONLogger) Log(e logging.Entry) {
+	_ = l.LogSync(context.Background(), e)
+}
+
+// LogSync implements LogSync.LogSync.
+func (l *JSONLogger) LogSync(_ context.Context, e logging.Entry) error {
+	m, err := toStructuredJSON(&e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	enc := json.NewEncoder(l.w)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(m); err != nil {
+		return errors.Wrap(err, "unable to write entry")
+	}
+
+	return nil
+}
+
+// Flush implements Logger.Flush.  If the underlying io.Writer has a Flush or
+// Sync method, e.g. bufio.Writer or os.File, it is called.
+func (l *JSONLogger) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var err error
+
+	switch w := l.w.(type) {
+	case interface{ Flush() error }:
+		err = w.Flush()
+	case interface{ Sync() error }:
+		err = w.Sync()
+		if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP) {
+			// stdout and stderr are often not syncable
+			err = nil
+		}
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to flush writer")
+	}
+
+	return nil
+}
+
+//nolint:cyclop
+func toStructuredJSON(e *logging.Entry) (map[string]any, error) {
+	m := make(map[string]any)
+
+	switch p := e.Payload.(type) {
+	case nil:
+	case *spb.Struct:
+		m = p.AsMap()
+	case string:
+		m[MessageKey] = p
+	default:
+		j, err := attr.ToJSON(p)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to convert payload")
+		}
+
+		if jm, ok := j.(map[string]any); ok {
+			m = jm
+		} else {
+			m[MessageKey] = j
+		}
+	}
+
+	m["severity"] = strings.ToUpper(e.Severity.String())
+
+	if !e.Timestamp.IsZero() {
+		m["timestamp"] = e.Timestamp.Format(time.RFC3339Nano)
+	}
+
+	if e.InsertID != "" {
+		m["logging.googleapis.com/insertId"] = e.InsertID
+	}
+
+	if len(e.Labels) > 0 {
+		m["logging.googleapis.com/labels"] = e.Labels
+	}
+
+	if e.Trace != "" {
+		m["logging.googleapis.com/trace"] = e.Trace
+	}
+
+	if e.SpanID != "" {
+		m["logging.googleapis.com/spanId"] = e.SpanID
+	}
+
+	if e.TraceSampled {
+		m["logging.googleapis.com/trace_sampled"] = true
+	}
+
+	if e.SourceLocation != nil {
+		b, err := protojson.Marshal(e.SourceLocation)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to convert source location")
+		}
+
+		m["logging.googleapis.com/sourceLocation"] = json.RawMessage(b)
+	}
+
+	if e.Operation != nil {
+		b, err := protojson.Marshal(e.Operation)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to convert operation")
+		}
+
+		m["logging.googleapis.com/operation"] = json.RawMessage(b)
+	}
+
+	if e.HTTPRequest != nil {
+		m["httpRequest"] = httpRequestToJSON(e.HTTPRequest)
+	}
+
+	return m, nil
+}
+
+// httpRequestToJSON maps a logging.HTTPRequest to the JSON representation of
+// the google.logging.type.HttpRequest message.
+func httpRequestToJSON(r *logging.HTTPRequest) map[string]any {
+	m := make(map[string]any)
+
+	if req := r.Request; req != nil {
+		m["requestMethod"] = req.Method
+		m["protocol"] = req.Proto
+
+		if req.URL != nil {
+			u := *req.URL
+			u.Fragment = ""
+			u.RawFragment = ""
+			m["requestUrl"] = u.String()
+		}
+
+		if ua := req.UserAgent(); ua != "" {
+			m["userAgent"] = ua
+		}
+
+		if ref := req.Referer(); ref != "" {
+			m["referer"] = ref
+		}
+	}
+
+	if r.Status != 0 {
+		m["status"] = r.Status
+	}
+
+	if r.RequestSize != 0 {
+		m["requestSize"] = strconv.FormatInt(r.RequestSize, 10)
+	}
+
+	if r.ResponseSize != 0 {
+		m["responseSize"] = strconv.FormatInt(r.ResponseSize, 10)
+	}
+
+	if r.Latency != 0 {
+		m["latency"] = strconv.FormatFloat(r.Latency.Seconds(), 'f', -1, 64) + "s"
+	}
+
+	if r.LocalIP != "" {
+		m["serverIp"] = r.LocalIP
+	}
+
+	if r.RemoteIP != "" {
+		m["remoteIp"] = r.RemoteIP
+	}
+
+	if r.CacheLookup {
+		m["cacheLookup"] = true
+	}
+
+	if r.CacheHit {
+		m["cacheHit"] = true
+	}
+
+	if r.CacheValidatedWithOriginServer {
+		m["cacheValidatedWithOriginServer"] = true
+	}
+
+	if r.CacheFillBytes != 0 {
+		m["cacheFillBytes"] = strconv.FormatInt(r.CacheFillBytes, 10)
+	}
+
+	return m
+}
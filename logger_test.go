@@ -15,11 +15,19 @@
 package gslog_test
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/logging"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"m4o.io/gslog"
 )
@@ -76,3 +84,106 @@ func TestDiscard_LogSync(t *testing.T) {
 	err := l.LogSync(context.Background(), logging.Entry{})
 	assert.NoError(t, err)
 }
+
+func TestJSONLogger_Log(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := gslog.NewJSONLogger(&buf)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/cow?how=now#brown", nil)
+	assert.NoError(t, err)
+	req.Header.Set("User-Agent", "test-agent")
+
+	l.Log(logging.Entry{
+		Timestamp: time.Date(2000, 1, 2, 3, 4, 5, 6000, time.UTC),
+		Severity:  logging.Warning,
+		Payload: &structpb.Struct{Fields: map[string]*structpb.Value{
+			"message":  structpb.NewStringValue("How now brown cow?"),
+			"severity": structpb.NewStringValue("overridden"),
+			"a":        structpb.NewNumberValue(1),
+		}},
+		Labels:       map[string]string{"how": "now"},
+		InsertID:     "insert-1",
+		Trace:        "projects/my-project/traces/52fc1643a9381fc674742bb0067101e7",
+		SpanID:       "d3e9e8c51cb190df",
+		TraceSampled: true,
+		HTTPRequest: &logging.HTTPRequest{
+			Request:      req,
+			Status:       http.StatusOK,
+			ResponseSize: 1024,
+			Latency:      1500 * time.Millisecond,
+		},
+		Operation: &logpb.LogEntryOperation{Id: "op-1", Producer: "gslog", First: true},
+		SourceLocation: &logpb.LogEntrySourceLocation{
+			File:     "logger_test.go",
+			Line:     42,
+			Function: "TestJSONLogger_Log",
+		},
+	})
+
+	assert.True(t, strings.HasSuffix(buf.String(), "\n"))
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"))
+
+	var got map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+
+	assert.Equal(t, map[string]any{
+		"message":                              "How now brown cow?",
+		"a":                                    float64(1),
+		"severity":                             "WARNING",
+		"timestamp":                            "2000-01-02T03:04:05.000006Z",
+		"logging.googleapis.com/insertId":      "insert-1",
+		"logging.googleapis.com/labels":        map[string]any{"how": "now"},
+		"logging.googleapis.com/trace":         "projects/my-project/traces/52fc1643a9381fc674742bb0067101e7",
+		"logging.googleapis.com/spanId":        "d3e9e8c51cb190df",
+		"logging.googleapis.com/trace_sampled": true,
+		"logging.googleapis.com/operation": map[string]any{
+			"id":       "op-1",
+			"producer": "gslog",
+			"first":    true,
+		},
+		"logging.googleapis.com/sourceLocation": map[string]any{
+			"file":     "logger_test.go",
+			"line":     "42",
+			"function": "TestJSONLogger_Log",
+		},
+		"httpRequest": map[string]any{
+			"requestMethod": "GET",
+			"requestUrl":    "https://example.com/cow?how=now",
+			"protocol":      "HTTP/1.1",
+			"userAgent":     "test-agent",
+			"status":        float64(200),
+			"responseSize":  "1024",
+			"latency":       "1.5s",
+		},
+	}, got)
+}
+
+func TestJSONLogger_LogSync_stringPayload(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := gslog.NewJSONLogger(&buf)
+
+	err := l.LogSync(context.Background(), logging.Entry{Payload: "How now brown cow?"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"message":"How now brown cow?","severity":"DEFAULT"}`+"\n", buf.String())
+}
+
+func TestJSONLogger_Flush(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := bufio.NewWriter(&buf)
+	l := gslog.NewJSONLogger(w)
+
+	l.Log(logging.Entry{Payload: "How now brown cow?"})
+	assert.Zero(t, buf.Len())
+
+	assert.NoError(t, l.Flush())
+	assert.NotZero(t, buf.Len())
+}
+
+func TestNewJSONLogger_nil(t *testing.T) {
+	assert.PanicsWithValue(t, "writer is nil", func() {
+		gslog.NewJSONLogger(nil)
+	})
+}
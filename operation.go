@@ -0,0 +1,102 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"context"
+	"sync/atomic"
+
+	"cloud.google.com/go/logging"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+type operationKey struct{}
+
+// operation holds the state of an operation shared by all the contexts
+// derived from the one returned by WithOperation.
+type operation struct {
+	id       string
+	producer string
+	started  atomic.Bool
+}
+
+type operationValue struct {
+	op   *operation
+	last bool
+}
+
+// WithOperation returns a new Context with an operation to be used in the
+// GCP log entries produced using that context.  The entries are grouped in
+// Cloud Logging by the operation's id and producer, which together should be
+// globally unique.
+//
+// The first entry logged for the operation is automatically marked as its
+// first entry.  Use EndOperation to mark the operation's last entry.
+func WithOperation(ctx context.Context, id, producer string) context.Context {
+	if id == "" {
+		panic("operation id is empty")
+	}
+
+	//nolint:exhaustruct
+	op := &operation{id: id, producer: producer}
+
+	return context.WithValue(ctx, operationKey{}, operationValue{op: op, last: false})
+}
+
+// EndOperation returns a new Context whose GCP log entries are marked as the
+// last entries of the operation previously attached using WithOperation.  If
+// no operation is attached to ctx, ctx is returned.
+func EndOperation(ctx context.Context) context.Context {
+	v, ok := ctx.Value(operationKey{}).(operationValue)
+	if !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, operationKey{}, operationValue{op: v.op, last: true})
+}
+
+// addOperation sets the operation attached to the context, if any, as the
+// entry's Operation and returns it.  Unless first is false, e.g. for entries
+// that are buffered, the entry is marked as the operation's first entry if
+// no other entry was.
+func addOperation(ctx context.Context, entry *logging.Entry, first bool) *operation {
+	v, ok := ctx.Value(operationKey{}).(operationValue)
+	if !ok {
+		return nil
+	}
+
+	//nolint:exhaustruct
+	entry.Operation = &logpb.LogEntryOperation{
+		Id:       v.op.id,
+		Producer: v.op.producer,
+		Last:     v.last,
+	}
+
+	if first {
+		v.op.markFirst(entry)
+	}
+
+	return v.op
+}
+
+// markFirst marks the entry as the operation's first entry, unless another
+// entry was.
+func (op *operation) markFirst(entry *logging.Entry) {
+	if op == nil || entry.Operation == nil {
+		return
+	}
+
+	entry.Operation.First = op.started.CompareAndSwap(false, true)
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"log/slog"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"m4o.io/gslog"
+)
+
+var _ = Describe("gslog operations", func() {
+	var ctx context.Context
+	var got *Got
+	var l *slog.Logger
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		got = &Got{}
+		l = slog.New(gslog.NewGcpHandler(got))
+	})
+
+	When("context has no operation", func() {
+		It("entries have no operation", func() {
+			l.InfoContext(ctx, "How now brown cow?")
+
+			Ω(got.LogEntry.Operation).Should(BeNil())
+		})
+
+		It("ending the operation is a no-op", func() {
+			Ω(gslog.EndOperation(ctx)).Should(Equal(ctx))
+		})
+	})
+
+	When("context is initialized with an empty operation id", func() {
+		It("should panic", func() {
+			Ω(func() {
+				gslog.WithOperation(ctx, "", "producer")
+			}).Should(PanicWith("operation id is empty"))
+		})
+	})
+
+	When("context is initialized with an operation", func() {
+		BeforeEach(func() {
+			ctx = gslog.WithOperation(ctx, "op-1", "gslog")
+		})
+
+		It("the first entry is marked as first", func() {
+			l.InfoContext(ctx, "How now brown cow?")
+
+			Ω(got.LogEntry.Operation).ShouldNot(BeNil())
+			Ω(got.LogEntry.Operation.GetId()).Should(Equal("op-1"))
+			Ω(got.LogEntry.Operation.GetProducer()).Should(Equal("gslog"))
+			Ω(got.LogEntry.Operation.GetFirst()).Should(BeTrue())
+			Ω(got.LogEntry.Operation.GetLast()).Should(BeFalse())
+
+			l.InfoContext(ctx, "How now brown cow?")
+
+			Ω(got.LogEntry.Operation.GetFirst()).Should(BeFalse())
+			Ω(got.LogEntry.Operation.GetLast()).Should(BeFalse())
+		})
+
+		Context("and the operation ended", func() {
+			It("the last entry is marked as last", func() {
+				l.InfoContext(ctx, "How now brown cow?")
+				l.InfoContext(gslog.EndOperation(ctx), "The rain in Spain lies mainly on the plane.")
+
+				Ω(got.LogEntry.Operation.GetId()).Should(Equal("op-1"))
+				Ω(got.LogEntry.Operation.GetFirst()).Should(BeFalse())
+				Ω(got.LogEntry.Operation.GetLast()).Should(BeTrue())
+			})
+		})
+	})
+})
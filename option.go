@@ -19,6 +19,8 @@ import (
 	"os"
 	"strconv"
 
+	"cloud.google.com/go/logging"
+
 	"m4o.io/gslog/internal/options"
 )
 
@@ -101,3 +103,49 @@ func WithReplaceAttr(replaceAttr AttrMapper) options.OptionProcessor {
 		o.ReplaceAttr = replaceAttr
 	}
 }
+
+// WithErrorReporting returns an option that causes records logged at
+// slog.LevelError, or higher, carrying an error attribute to be written in
+// the format recognized by Cloud Error Reporting.  The payload is marked with
+// the ReportedErrorEvent "@type", and includes a "stack_trace", taken from the
+// error if it carries one, e.g. those created by github.com/pkg/errors, or the
+// caller's goroutine stack otherwise, as well as a "serviceContext" made of
+// the supplied service and version.
+func WithErrorReporting(service, version string) options.OptionProcessor {
+	if service == "" {
+		panic("service is empty")
+	}
+
+	return func(o *options.Options) {
+		o.ErrorReportingService = service
+		o.ErrorReportingVersion = version
+	}
+}
+
+// ErrorHandler is called when an entry could not be logged.  It receives the
+// error, the original slog.Record and the logging.Entry built from it, e.g.
+// so that the record can be re-emitted by a fallback handler.
+type ErrorHandler func(err error, r slog.Record, e logging.Entry)
+
+// WithOnError returns an option that specifies the ErrorHandler called when
+// an entry could not be logged.  By default, the error is written to
+// os.Stderr.
+func WithOnError(onError ErrorHandler) options.OptionProcessor {
+	if onError == nil {
+		panic("error handler is nil")
+	}
+
+	return func(o *options.Options) {
+		o.OnError = options.ErrorHandler(onError)
+	}
+}
+
+// WithErrorsReturned returns an option that causes the handler's Handle
+// method to return the errors encountered when logging entries, in addition
+// to passing them to the ErrorHandler.  Note that slog.Logger ignores the
+// errors returned by its handler.
+func WithErrorsReturned() options.OptionProcessor {
+	return func(o *options.Options) {
+		o.ReturnErrors = true
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"testing"
 
+	"cloud.google.com/go/logging"
 	"github.com/stretchr/testify/assert"
 
 	"m4o.io/gslog"
@@ -107,3 +108,17 @@ func TestWithReplaceAttr(t *testing.T) {
 	o := options.ApplyOptions(gslog.WithReplaceAttr(ra), gslog.WithDefaultLogLeveler(slog.LevelInfo))
 	assert.Equal(t, s, o.ReplaceAttr(nil, slog.String("unused", "string")))
 }
+
+func TestWithErrorReporting(t *testing.T) {
+	o := options.ApplyOptions(gslog.WithErrorReporting("my-service", "v1.2.3"))
+	assert.Equal(t, "my-service", o.ErrorReportingService)
+	assert.Equal(t, "v1.2.3", o.ErrorReportingVersion)
+}
+
+func TestWithOnError(t *testing.T) {
+	assert.Panics(t, func() { gslog.WithOnError(nil) })
+
+	o := options.ApplyOptions(gslog.WithOnError(func(error, slog.Record, logging.Entry) {}), gslog.WithErrorsReturned())
+	assert.NotNil(t, o.OnError)
+	assert.True(t, o.ReturnErrors)
+}
@@ -0,0 +1,343 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/pkg/errors"
+
+	"m4o.io/gslog/internal/level"
+)
+
+// DefaultFlushTimeout is the time QueuedLogger.Flush waits for the queue to
+// drain when the Queue does not specify a FlushTimeout.
+const DefaultFlushTimeout = 5 * time.Second
+
+// OverflowPolicy identifies what a QueuedLogger does with an entry logged
+// while its queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the caller until there is room in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the entry being logged.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest entry in the queue to make room
+	// for the entry being logged.
+	OverflowDropOldest
+	// OverflowDropBelow drops the entry being logged if its severity is lower
+	// than the Queue's Level, otherwise it blocks the caller until there is
+	// room in the queue.
+	OverflowDropBelow
+)
+
+// String returns the name of the policy.
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropNewest:
+		return "drop_newest"
+	case OverflowDropOldest:
+		return "drop_oldest"
+	case OverflowDropBelow:
+		return "drop_below"
+	default:
+		return "unknown(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// Queue configures a QueuedLogger.
+type Queue struct {
+	// Size is the maximum number of entries held in the queue.
+	Size int
+
+	// Overflow is the policy applied to the entries logged while the queue
+	// is full.
+	Overflow OverflowPolicy
+
+	// Level is the level below which entries are dropped when the queue is
+	// full, if Overflow is OverflowDropBelow.
+	Level slog.Level
+
+	// FlushTimeout is the time Flush waits for the queue to drain.  If
+	// zero, or less, DefaultFlushTimeout is used.
+	FlushTimeout time.Duration
+}
+
+// QueueStats holds the counters of a QueuedLogger.
+type QueueStats struct {
+	// Enqueued is the number of entries added to the queue.
+	Enqueued uint64
+	// Dropped is the number of entries dropped, either because the queue was
+	// full or because the QueuedLogger was closed.
+	Dropped uint64
+	// Flushed is the number of entries passed on to the wrapped Logger.
+	Flushed uint64
+}
+
+// QueuedLogger is a Logger that puts the entries logged via Log in a bounded
+// queue, from which a goroutine passes them on to the wrapped Logger.  The
+// queue's Overflow policy determines whether callers block or entries are
+// dropped when it is full.
+//
+// LogSync bypasses the queue and calls the wrapped Logger directly.
+type QueuedLogger struct {
+	log          Logger
+	entries      chan logging.Entry
+	overflow     OverflowPolicy
+	severity     logging.Severity
+	flushTimeout time.Duration
+
+	enqueued atomic.Uint64
+	dropped  atomic.Uint64
+	flushed  atomic.Uint64
+
+	// logged is the number of entries passed to Log, each of which is
+	// eventually accounted for in processed: once it is passed on to the
+	// wrapped Logger or dropped.
+	logged atomic.Uint64
+
+	// mu guards processed and progress, which is closed and cleared whenever
+	// processed changes so that Flush can wait for the queue to drain.
+	mu        sync.Mutex
+	processed uint64
+	progress  chan struct{}
+
+	closed atomic.Bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+var _ Logger = (*QueuedLogger)(nil)
+
+// NewQueuedLogger creates a QueuedLogger that puts the entries logged in a
+// queue in front of logger, configured by queue.  Close should be called to
+// stop the QueuedLogger's goroutine once it is no longer needed.
+func NewQueuedLogger(logger Logger, queue Queue) *QueuedLogger {
+	if logger == nil {
+		panic("logger is nil")
+	}
+
+	if queue.Size <= 0 {
+		panic("queue size must be positive")
+	}
+
+	if queue.FlushTimeout <= 0 {
+		queue.FlushTimeout = DefaultFlushTimeout
+	}
+
+	q := &QueuedLogger{
+		log:          logger,
+		entries:      make(chan logging.Entry, queue.Size),
+		overflow:     queue.Overflow,
+		severity:     level.ToSeverity(queue.Level),
+		flushTimeout: queue.FlushTimeout,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	go q.run()
+
+	return q
+}
+
+// Log adds the Entry to the queue, applying the overflow policy if the
+// queue is full.  Entries logged after Close are dropped.
+func (q *QueuedLogger) Log(e logging.Entry) {
+	if q.closed.Load() {
+		q.dropped.Add(1)
+
+		return
+	}
+
+	q.logged.Add(1)
+
+	if q.enqueue(e) {
+		q.enqueued.Add(1)
+	} else {
+		q.dropped.Add(1)
+		q.advance()
+	}
+}
+
+// LogSync logs the Entry synchronously via the wrapped Logger, bypassing the
+// queue.
+func (q *QueuedLogger) LogSync(ctx context.Context, e logging.Entry) error {
+	return q.log.LogSync(ctx, e) //nolint:wrapcheck
+}
+
+// Flush blocks until the entries logged before the call have been
+// passed on to the wrapped Logger, or the Queue's FlushTimeout elapses, and
+// then flushes the wrapped Logger.
+func (q *QueuedLogger) Flush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), q.flushTimeout)
+	defer cancel()
+
+	return q.FlushContext(ctx)
+}
+
+// FlushContext blocks until the entries logged before the call have been
+// passed on to the wrapped Logger, or the context is done, and then
+// flushes the wrapped Logger.
+func (q *QueuedLogger) FlushContext(ctx context.Context) error {
+	if err := q.drain(ctx); err != nil {
+		return err
+	}
+
+	return q.log.Flush() //nolint:wrapcheck
+}
+
+// Close drains the queue, as FlushContext does, and stops the QueuedLogger's
+// goroutine.  Entries logged after Close are dropped.
+func (q *QueuedLogger) Close(ctx context.Context) error {
+	if q.closed.Swap(true) {
+		return nil
+	}
+
+	err := q.FlushContext(ctx)
+
+	close(q.stop)
+	<-q.done
+
+	// drop whatever the drain did not get to
+	for {
+		select {
+		case <-q.entries:
+			q.dropped.Add(1)
+		default:
+			return err
+		}
+	}
+}
+
+// Stats returns the QueuedLogger's counters.
+func (q *QueuedLogger) Stats() QueueStats {
+	return QueueStats{
+		Enqueued: q.enqueued.Load(),
+		Dropped:  q.dropped.Load(),
+		Flushed:  q.flushed.Load(),
+	}
+}
+
+// Len returns the number of entries currently in the queue.
+func (q *QueuedLogger) Len() int {
+	return len(q.entries)
+}
+
+func (q *QueuedLogger) enqueue(e logging.Entry) bool {
+	select {
+	case q.entries <- e:
+		return true
+	default:
+	}
+
+	switch q.overflow {
+	case OverflowDropNewest:
+		return false
+	case OverflowDropOldest:
+		for {
+			select {
+			case <-q.entries:
+				q.dropped.Add(1)
+				q.advance()
+			default:
+			}
+
+			select {
+			case q.entries <- e:
+				return true
+			default:
+			}
+		}
+	case OverflowDropBelow:
+		if e.Severity < q.severity {
+			return false
+		}
+	case OverflowBlock:
+	}
+
+	select {
+	case q.entries <- e:
+		return true
+	case <-q.stop:
+		return false
+	}
+}
+
+func (q *QueuedLogger) run() {
+	defer close(q.done)
+
+	for {
+		select {
+		case e := <-q.entries:
+			q.log.Log(e)
+			q.flushed.Add(1)
+			q.advance()
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// advance records that an entry logged was processed, waking up any pending
+// drain.
+func (q *QueuedLogger) advance() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.processed++
+
+	if q.progress != nil {
+		close(q.progress)
+		q.progress = nil
+	}
+}
+
+// drain waits until the entries logged before the call have been processed.
+func (q *QueuedLogger) drain(ctx context.Context) error {
+	target := q.logged.Load()
+
+	for {
+		q.mu.Lock()
+
+		if q.processed >= target {
+			q.mu.Unlock()
+
+			return nil
+		}
+
+		if q.progress == nil {
+			q.progress = make(chan struct{})
+		}
+
+		progress := q.progress
+
+		q.mu.Unlock()
+
+		select {
+		case <-progress:
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "unable to drain queue")
+		}
+	}
+}
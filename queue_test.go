@@ -0,0 +1,226 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/stretchr/testify/assert"
+
+	"m4o.io/gslog"
+)
+
+// gatedLogger is a Logger whose Log blocks until its gate is opened,
+// signalling on entered once the first entry arrives.
+type gatedLogger struct {
+	syncCollector
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func newGatedLogger() *gatedLogger {
+	return &gatedLogger{
+		entered: make(chan struct{}, 1),
+		gate:    make(chan struct{}),
+	}
+}
+
+func (g *gatedLogger) Log(e logging.Entry) {
+	select {
+	case g.entered <- struct{}{}:
+	default:
+	}
+
+	<-g.gate
+
+	g.syncCollector.Log(e)
+}
+
+func (g *gatedLogger) payloads() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var p []string
+	for _, e := range g.entries {
+		p = append(p, e.Payload.(string))
+	}
+
+	return p
+}
+
+// fill logs the first entry, waits for the QueuedLogger's goroutine to block
+// on it, and fills the queue with the remaining ones.
+func fill(q *gslog.QueuedLogger, g *gatedLogger, payloads ...string) {
+	q.Log(logging.Entry{Payload: payloads[0]})
+	<-g.entered
+
+	for _, p := range payloads[1:] {
+		q.Log(logging.Entry{Payload: p})
+	}
+}
+
+func TestQueuedLogger_dropNewest(t *testing.T) {
+	g := newGatedLogger()
+	q := gslog.NewQueuedLogger(g, gslog.Queue{Size: 2, Overflow: gslog.OverflowDropNewest})
+	defer q.Close(context.Background())
+
+	fill(q, g, "one", "two", "three")
+	q.Log(logging.Entry{Payload: "four"})
+
+	assert.Equal(t, 2, q.Len())
+
+	close(g.gate)
+	assert.NoError(t, q.Flush())
+
+	assert.Equal(t, []string{"one", "two", "three"}, g.payloads())
+	assert.Equal(t, gslog.QueueStats{Enqueued: 3, Dropped: 1, Flushed: 3}, q.Stats())
+}
+
+func TestQueuedLogger_dropOldest(t *testing.T) {
+	g := newGatedLogger()
+	q := gslog.NewQueuedLogger(g, gslog.Queue{Size: 2, Overflow: gslog.OverflowDropOldest})
+	defer q.Close(context.Background())
+
+	fill(q, g, "one", "two", "three")
+	q.Log(logging.Entry{Payload: "four"})
+
+	close(g.gate)
+	assert.NoError(t, q.Flush())
+
+	assert.Equal(t, []string{"one", "three", "four"}, g.payloads())
+	assert.Equal(t, gslog.QueueStats{Enqueued: 4, Dropped: 1, Flushed: 3}, q.Stats())
+}
+
+func TestQueuedLogger_dropBelow(t *testing.T) {
+	g := newGatedLogger()
+	q := gslog.NewQueuedLogger(g, gslog.Queue{Size: 1, Overflow: gslog.OverflowDropBelow, Level: slog.LevelWarn})
+	defer q.Close(context.Background())
+
+	fill(q, g, "one", "two")
+	q.Log(logging.Entry{Payload: "info", Severity: logging.Info})
+
+	logged := make(chan struct{})
+	go func() {
+		q.Log(logging.Entry{Payload: "error", Severity: logging.Error})
+		close(logged)
+	}()
+
+	select {
+	case <-logged:
+		assert.Fail(t, "entry at the level was not blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(g.gate)
+	<-logged
+	assert.NoError(t, q.Flush())
+
+	assert.Equal(t, []string{"one", "two", "error"}, g.payloads())
+	assert.Equal(t, gslog.QueueStats{Enqueued: 3, Dropped: 1, Flushed: 3}, q.Stats())
+}
+
+func TestQueuedLogger_block(t *testing.T) {
+	g := newGatedLogger()
+	q := gslog.NewQueuedLogger(g, gslog.Queue{Size: 1, Overflow: gslog.OverflowBlock})
+	defer q.Close(context.Background())
+
+	fill(q, g, "one", "two")
+
+	logged := make(chan struct{})
+	go func() {
+		q.Log(logging.Entry{Payload: "three"})
+		close(logged)
+	}()
+
+	select {
+	case <-logged:
+		assert.Fail(t, "entry was not blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(g.gate)
+	<-logged
+	assert.NoError(t, q.Flush())
+
+	assert.Equal(t, []string{"one", "two", "three"}, g.payloads())
+	assert.Equal(t, gslog.QueueStats{Enqueued: 3, Dropped: 0, Flushed: 3}, q.Stats())
+}
+
+func TestQueuedLogger_FlushContext_deadline(t *testing.T) {
+	g := newGatedLogger()
+	q := gslog.NewQueuedLogger(g, gslog.Queue{Size: 2})
+
+	fill(q, g, "one", "two")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := q.FlushContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(g.gate)
+	assert.NoError(t, q.FlushContext(context.Background()))
+	assert.Equal(t, []string{"one", "two"}, g.payloads())
+
+	assert.NoError(t, q.Close(context.Background()))
+}
+
+func TestQueuedLogger_Close(t *testing.T) {
+	c := &syncCollector{}
+	q := gslog.NewQueuedLogger(c, gslog.Queue{Size: 8})
+
+	q.Log(logging.Entry{Payload: "one"})
+	q.Log(logging.Entry{Payload: "two"})
+
+	assert.NoError(t, q.Close(context.Background()))
+	assert.Equal(t, 2, c.len())
+
+	q.Log(logging.Entry{Payload: "three"})
+
+	assert.Equal(t, 2, c.len())
+	assert.Equal(t, gslog.QueueStats{Enqueued: 2, Dropped: 1, Flushed: 2}, q.Stats())
+	assert.NoError(t, q.Close(context.Background()))
+}
+
+func TestQueuedLogger_LogSync(t *testing.T) {
+	c := &syncCollector{}
+	q := gslog.NewQueuedLogger(c, gslog.Queue{Size: 8})
+	defer q.Close(context.Background())
+
+	assert.NoError(t, q.LogSync(context.Background(), logging.Entry{Payload: "one"}))
+	assert.Equal(t, 1, c.len())
+	assert.Equal(t, gslog.QueueStats{}, q.Stats())
+}
+
+func TestNewQueuedLogger_invalid(t *testing.T) {
+	assert.Panics(t, func() {
+		gslog.NewQueuedLogger(nil, gslog.Queue{Size: 1})
+	})
+	assert.Panics(t, func() {
+		gslog.NewQueuedLogger(&collector{}, gslog.Queue{})
+	})
+}
+
+func TestOverflowPolicy_String(t *testing.T) {
+	assert.Equal(t, "block", gslog.OverflowBlock.String())
+	assert.Equal(t, "drop_newest", gslog.OverflowDropNewest.String())
+	assert.Equal(t, "drop_oldest", gslog.OverflowDropOldest.String())
+	assert.Equal(t, "drop_below", gslog.OverflowDropBelow.String())
+	assert.Equal(t, "unknown(42)", gslog.OverflowPolicy(42).String())
+}
@@ -0,0 +1,44 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"context"
+
+	"cloud.google.com/go/logging"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+)
+
+type resourceKey struct{}
+
+// WithResource returns a new Context with a monitored resource to be used in
+// the GCP log entries produced using that context.  It takes precedence over
+// any resource set by the handler's options, e.g.
+// resource.WithDetectedResource.
+func WithResource(ctx context.Context, res *mrpb.MonitoredResource) context.Context {
+	if res == nil {
+		panic("resource is nil")
+	}
+
+	return context.WithValue(ctx, resourceKey{}, res)
+}
+
+// addResource sets the monitored resource attached to the context, if any, as
+// the entry's Resource.
+func addResource(ctx context.Context, entry *logging.Entry) {
+	if res, ok := ctx.Value(resourceKey{}).(*mrpb.MonitoredResource); ok {
+		entry.Resource = res
+	}
+}
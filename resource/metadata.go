@@ -0,0 +1,60 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"net/http"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/pkg/errors"
+)
+
+// Metadata wraps the lookups of the GCE metadata server that are used when
+// detecting the monitored resource.  This interface facilitates testing
+// against a fake metadata server.
+type Metadata interface {
+	// OnGCE reports whether the metadata server is available, i.e. whether
+	// the program is running on Google Compute Engine, or a platform built on
+	// it, like GKE, Cloud Run and Cloud Functions.
+	OnGCE() bool
+
+	// Get returns the value of the metadata key suffix, relative to
+	// "http://metadata/computeMetadata/v1/", e.g. "project/project-id".
+	Get(suffix string) (string, error)
+}
+
+// NewMetadata returns a Metadata backed by the GCE metadata server.  The
+// GCE_METADATA_HOST environment variable can be used to direct the lookups to
+// a fake metadata server.  If client is nil, a default http.Client is used.
+func NewMetadata(client *http.Client) Metadata {
+	return gceMetadata{client: metadata.NewClient(client)}
+}
+
+type gceMetadata struct {
+	client *metadata.Client
+}
+
+func (m gceMetadata) OnGCE() bool {
+	return metadata.OnGCE()
+}
+
+func (m gceMetadata) Get(suffix string) (string, error) {
+	v, err := m.client.Get(suffix)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get metadata %q", suffix)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package resource contains options for setting the monitored resource of
+logging records from the environment the program runs in, e.g. Cloud Run,
+Cloud Functions, GKE or GCE.
+
+Placing the options in a separate package minimizes the dependencies pulled in
+by those who do not need the monitored resource detected.
+*/
+package resource
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/logging"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+
+	"m4o.io/gslog/internal/options"
+)
+
+const (
+	serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// Detector detects the monitored resource of the environment the program
+// runs in.  The zero value uses the GCE metadata server and the process's
+// environment variables.
+type Detector struct {
+	// Metadata is used to look up the project, zone, region and cluster.  If
+	// nil, the GCE metadata server is used.
+	Metadata Metadata
+
+	// PodinfoRoot is the directory holding the Kubernetes Downward API
+	// podinfo files, see k8s.WithPodinfoLabels.  When running on GKE, the pod
+	// name and namespace are read from its "name" and "namespace" files.
+	PodinfoRoot string
+
+	// Getenv looks up environment variables.  If nil, os.Getenv is used.
+	Getenv func(key string) string
+}
+
+// WithDetectedResource returns an option that directs the slog.Handler to set
+// the logging.Entry's Resource to the monitored resource detected, once, by
+// the supplied Detector.  If no resource can be detected, the Resource is left
+// for the logging client to decide.
+//
+// A resource attached to the context via gslog.WithResource takes precedence
+// over the detected one.
+func WithDetectedResource(d Detector) options.OptionProcessor {
+	return func(options *options.Options) {
+		res := d.Detect()
+		if res == nil {
+			return
+		}
+
+		options.EntryAugmentors = append(options.EntryAugmentors,
+			func(_ context.Context, entry *logging.Entry, _ []string) {
+				if entry.Resource == nil {
+					entry.Resource = res
+				}
+			})
+	}
+}
+
+// Detect returns the monitored resource of the environment the program runs
+// in, or nil if it cannot be detected.  The following are detected, in order:
+//
+//   - Cloud Run jobs, "cloud_run_job", via CLOUD_RUN_JOB
+//   - Cloud Functions, "cloud_function", via FUNCTION_TARGET or FUNCTION_NAME
+//   - Cloud Run services, "cloud_run_revision", via K_SERVICE
+//   - GKE containers, "k8s_container", via KUBERNETES_SERVICE_HOST
+//   - GCE instances, "gce_instance", via the metadata server
+func (d Detector) Detect() *mrpb.MonitoredResource {
+	d = d.withDefaults()
+
+	if !d.Metadata.OnGCE() {
+		return nil
+	}
+
+	projectID := d.get("project/project-id")
+
+	switch {
+	case d.Getenv("CLOUD_RUN_JOB") != "":
+		return newResource("cloud_run_job", projectID, map[string]string{
+			"job_name": d.Getenv("CLOUD_RUN_JOB"),
+			"location": d.region(),
+		})
+	case d.Getenv("FUNCTION_TARGET") != "" || d.Getenv("FUNCTION_NAME") != "":
+		name := d.Getenv("K_SERVICE")
+		if name == "" {
+			name = d.Getenv("FUNCTION_NAME")
+		}
+
+		return newResource("cloud_function", projectID, map[string]string{
+			"function_name": name,
+			"region":        d.region(),
+		})
+	case d.Getenv("K_SERVICE") != "":
+		return newResource("cloud_run_revision", projectID, map[string]string{
+			"service_name":       d.Getenv("K_SERVICE"),
+			"revision_name":      d.Getenv("K_REVISION"),
+			"configuration_name": d.Getenv("K_CONFIGURATION"),
+			"location":           d.region(),
+		})
+	case d.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		return newResource("k8s_container", projectID, map[string]string{
+			"location":       d.get("instance/attributes/cluster-location"),
+			"cluster_name":   d.get("instance/attributes/cluster-name"),
+			"namespace_name": d.podinfo("namespace", serviceAccountNamespace, "NAMESPACE_NAME"),
+			"pod_name":       d.podinfo("name", "", "HOSTNAME"),
+			"container_name": d.Getenv("CONTAINER_NAME"),
+		})
+	default:
+		return newResource("gce_instance", projectID, map[string]string{
+			"instance_id": d.get("instance/id"),
+			"zone":        path.Base(d.get("instance/zone")),
+		})
+	}
+}
+
+func (d Detector) withDefaults() Detector {
+	if d.Metadata == nil {
+		d.Metadata = NewMetadata(nil)
+	}
+
+	if d.Getenv == nil {
+		d.Getenv = os.Getenv
+	}
+
+	return d
+}
+
+// get returns the metadata value for suffix, or an empty string if it cannot
+// be obtained.
+func (d Detector) get(suffix string) string {
+	v, err := d.Metadata.Get(suffix)
+	if err != nil {
+		slog.Warn("Unable to get metadata", "suffix", suffix, "error", err)
+
+		return ""
+	}
+
+	return strings.TrimSpace(v)
+}
+
+// region returns the region of the instance, which the metadata server returns
+// in the form "projects/<number>/regions/<region>".
+func (d Detector) region() string {
+	r := d.get("instance/region")
+	if r == "" {
+		return ""
+	}
+
+	return path.Base(r)
+}
+
+// podinfo returns the contents of the podinfo file name.  If it cannot be
+// read, the contents of the fallback file or, lastly, the value of the
+// environment variable key are returned.
+func (d Detector) podinfo(name, fallback, key string) string {
+	paths := make([]string, 0, 2)
+
+	if d.PodinfoRoot != "" {
+		paths = append(paths, filepath.Join(d.PodinfoRoot, name))
+	}
+
+	if fallback != "" {
+		paths = append(paths, fallback)
+	}
+
+	for _, p := range paths {
+		if b, err := os.ReadFile(p); err == nil {
+			return strings.TrimSpace(string(b))
+		}
+	}
+
+	return d.Getenv(key)
+}
+
+func newResource(typ, projectID string, labels map[string]string) *mrpb.MonitoredResource {
+	labels["project_id"] = projectID
+
+	for k, v := range labels {
+		if v == "" {
+			delete(labels, k)
+		}
+	}
+
+	return &mrpb.MonitoredResource{Type: typ, Labels: labels}
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/logging"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+
+	"m4o.io/gslog/internal/options"
+	"m4o.io/gslog/resource"
+)
+
+// fakeMetadataServer serves the supplied metadata values in the manner of the
+// GCE metadata server.
+func fakeMetadataServer(values map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Metadata-Flavor", "Google")
+
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		v, ok := values[strings.TrimPrefix(r.URL.Path, "/computeMetadata/v1/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(v))
+	}))
+}
+
+var _ = Describe("Monitored resource detection", func() {
+	var server *httptest.Server
+	var env map[string]string
+	var detector resource.Detector
+
+	BeforeEach(func() {
+		server = fakeMetadataServer(map[string]string{
+			"project/project-id":                   "my-project",
+			"instance/id":                          "1234567890",
+			"instance/zone":                        "projects/123/zones/us-central1-a",
+			"instance/region":                      "projects/123/regions/us-central1",
+			"instance/attributes/cluster-name":     "my-cluster",
+			"instance/attributes/cluster-location": "us-central1",
+		})
+		DeferCleanup(server.Close)
+
+		Ω(os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))).Should(Succeed())
+		DeferCleanup(os.Unsetenv, "GCE_METADATA_HOST")
+
+		env = make(map[string]string)
+		detector = resource.Detector{
+			Metadata: resource.NewMetadata(nil),
+			Getenv:   func(key string) string { return env[key] },
+		}
+	})
+
+	When("running on GCE", func() {
+		It("a gce_instance is detected", func() {
+			Ω(detector.Detect()).Should(matchResource("gce_instance", map[string]string{
+				"project_id":  "my-project",
+				"instance_id": "1234567890",
+				"zone":        "us-central1-a",
+			}))
+		})
+	})
+
+	When("running on Cloud Run", func() {
+		BeforeEach(func() {
+			env["K_SERVICE"] = "my-service"
+			env["K_REVISION"] = "my-service-00001"
+			env["K_CONFIGURATION"] = "my-service"
+		})
+
+		It("a cloud_run_revision is detected", func() {
+			Ω(detector.Detect()).Should(matchResource("cloud_run_revision", map[string]string{
+				"project_id":         "my-project",
+				"service_name":       "my-service",
+				"revision_name":      "my-service-00001",
+				"configuration_name": "my-service",
+				"location":           "us-central1",
+			}))
+		})
+
+		Context("as a Cloud Function", func() {
+			BeforeEach(func() {
+				env["FUNCTION_TARGET"] = "HelloWorld"
+			})
+
+			It("a cloud_function is detected", func() {
+				Ω(detector.Detect()).Should(matchResource("cloud_function", map[string]string{
+					"project_id":    "my-project",
+					"function_name": "my-service",
+					"region":        "us-central1",
+				}))
+			})
+		})
+	})
+
+	When("running as a Cloud Run job", func() {
+		BeforeEach(func() {
+			env["CLOUD_RUN_JOB"] = "my-job"
+		})
+
+		It("a cloud_run_job is detected", func() {
+			Ω(detector.Detect()).Should(matchResource("cloud_run_job", map[string]string{
+				"project_id": "my-project",
+				"job_name":   "my-job",
+				"location":   "us-central1",
+			}))
+		})
+	})
+
+	When("running on GKE", func() {
+		BeforeEach(func() {
+			env["KUBERNETES_SERVICE_HOST"] = "10.0.0.1"
+			env["CONTAINER_NAME"] = "my-container"
+			detector.PodinfoRoot = "testdata/etc/podinfo"
+		})
+
+		It("a k8s_container is detected", func() {
+			Ω(detector.Detect()).Should(matchResource("k8s_container", map[string]string{
+				"project_id":     "my-project",
+				"location":       "us-central1",
+				"cluster_name":   "my-cluster",
+				"namespace_name": "my-namespace",
+				"pod_name":       "my-pod-7d9f",
+				"container_name": "my-container",
+			}))
+		})
+	})
+
+	When("used as an option", func() {
+		var o *options.Options
+
+		BeforeEach(func() {
+			env["K_SERVICE"] = "my-service"
+			o = &options.Options{}
+			resource.WithDetectedResource(detector)(o)
+		})
+
+		It("entries without a resource are given the detected one", func() {
+			e := &logging.Entry{}
+			for _, a := range o.EntryAugmentors {
+				a(context.Background(), e, nil)
+			}
+
+			Ω(e.Resource.GetType()).Should(Equal("cloud_run_revision"))
+		})
+
+		It("entries with a resource keep theirs", func() {
+			res := &mrpb.MonitoredResource{Type: "global"}
+			e := &logging.Entry{Resource: res}
+			for _, a := range o.EntryAugmentors {
+				a(context.Background(), e, nil)
+			}
+
+			Ω(e.Resource).Should(BeIdenticalTo(res))
+		})
+	})
+})
+
+func matchResource(typ string, labels map[string]string) OmegaMatcher {
+	return And(
+		WithTransform(func(r *mrpb.MonitoredResource) string { return r.GetType() }, Equal(typ)),
+		WithTransform(func(r *mrpb.MonitoredResource) map[string]string { return r.GetLabels() }, Equal(labels)),
+	)
+}
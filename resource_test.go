@@ -0,0 +1,54 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"cloud.google.com/go/logging"
+	"github.com/stretchr/testify/assert"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+
+	"m4o.io/gslog"
+	"m4o.io/gslog/internal/options"
+)
+
+func TestWithResource(t *testing.T) {
+	detected := &mrpb.MonitoredResource{Type: "cloud_run_revision"}
+	override := &mrpb.MonitoredResource{Type: "cloud_run_job"}
+
+	withDetected := func(o *options.Options) {
+		o.EntryAugmentors = append(o.EntryAugmentors, func(_ context.Context, e *logging.Entry, _ []string) {
+			e.Resource = detected
+		})
+	}
+
+	got := &Got{}
+	l := slog.New(gslog.NewGcpHandler(got, withDetected))
+
+	l.Info("How now brown cow?")
+	assert.Same(t, detected, got.LogEntry.Resource)
+
+	l.InfoContext(gslog.WithResource(context.Background(), override), "How now brown cow?")
+	assert.Same(t, override, got.LogEntry.Resource)
+}
+
+func TestWithResource_nil(t *testing.T) {
+	assert.PanicsWithValue(t, "resource is nil", func() {
+		gslog.WithResource(context.Background(), nil)
+	})
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"context"
+	"log/slog"
+
+	"cloud.google.com/go/logging"
+	spb "google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog/internal/level"
+	"m4o.io/gslog/internal/options"
+)
+
+const (
+	// LogNameKey is the key of the reserved attribute used by RouteByLogName
+	// to select the Logger a record is logged to.  The attribute is removed
+	// from the payload of handlers configured with routes.
+	LogNameKey = "log_name"
+)
+
+// RouteMatcher reports whether an instance of logging.Entry is to be routed.
+// The current context and group path is provided, in case they are needed by
+// the matcher.
+type RouteMatcher options.RouteMatcher
+
+// WithRoute returns an option that directs entries matched by match to the
+// supplied logger rather than the handler's.  Routes are tried in the order
+// they are supplied and the first that matches wins; entries that match no
+// route are logged to the handler's Logger.
+//
+// The matching is done once the entry has been fully built, i.e. after all
+// the entry augmentors have been applied, so all the routes share the same
+// handler configuration.
+func WithRoute(match RouteMatcher, logger Logger) options.OptionProcessor {
+	if match == nil {
+		panic("matcher is nil")
+	}
+
+	if logger == nil {
+		panic("logger is nil")
+	}
+
+	return func(o *options.Options) {
+		o.Routes = append(o.Routes, options.Route{Match: options.RouteMatcher(match), Logger: logger})
+	}
+}
+
+// RouteBySeverity returns a RouteMatcher that matches entries whose severity
+// is at least that of the supplied level.
+func RouteBySeverity(lvl slog.Level) RouteMatcher {
+	severity := level.ToSeverity(lvl)
+
+	return func(_ context.Context, e *logging.Entry, _ []string) bool {
+		return e.Severity >= severity
+	}
+}
+
+// RouteByGroup returns a RouteMatcher that matches entries logged by a
+// handler whose outermost group, see slog.Logger.WithGroup, is name.
+func RouteByGroup(name string) RouteMatcher {
+	return func(_ context.Context, _ *logging.Entry, groups []string) bool {
+		return len(groups) > 0 && groups[0] == name
+	}
+}
+
+// RouteByLogName returns a RouteMatcher that matches entries with a root
+// LogNameKey attribute, i.e. not nested in any group, whose value is name.
+func RouteByLogName(name string) RouteMatcher {
+	return func(_ context.Context, e *logging.Entry, _ []string) bool {
+		payload, ok := e.Payload.(*spb.Struct)
+		if !ok {
+			return false
+		}
+
+		return payload.GetFields()[LogNameKey].GetStringValue() == name
+	}
+}
+
+// route returns the Logger the entry is to be logged to.
+func (h *GcpHandler) route(ctx context.Context, e *logging.Entry) Logger {
+	if len(h.routes) == 0 {
+		return h.log
+	}
+
+	logger := h.log
+
+	for _, r := range h.routes {
+		if r.Match(ctx, e, h.groups) {
+			logger = r.Logger
+
+			break
+		}
+	}
+
+	if payload, ok := e.Payload.(*spb.Struct); ok {
+		delete(payload.GetFields(), LogNameKey)
+	}
+
+	return logger
+}
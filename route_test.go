@@ -0,0 +1,113 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"cloud.google.com/go/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog"
+)
+
+type flushCounter struct {
+	Got
+	flushes int
+	err     error
+}
+
+func (f *flushCounter) Flush() error {
+	f.flushes++
+	return f.err
+}
+
+func TestWithRoute(t *testing.T) {
+	app := &Got{}
+	audit := &Got{}
+	access := &Got{}
+	alerts := &Got{}
+
+	l := slog.New(gslog.NewGcpHandler(app,
+		gslog.WithRoute(gslog.RouteByLogName("audit"), audit),
+		gslog.WithRoute(gslog.RouteByGroup("access"), access),
+		gslog.WithRoute(gslog.RouteBySeverity(slog.LevelError), alerts),
+	))
+
+	reset := func() {
+		*app, *audit, *access, *alerts = Got{}, Got{}, Got{}, Got{}
+	}
+
+	l.Info("How now brown cow?")
+	assert.NotNil(t, app.LogEntry.Payload)
+	assert.Nil(t, audit.LogEntry.Payload)
+	assert.Nil(t, access.LogEntry.Payload)
+	assert.Nil(t, alerts.LogEntry.Payload)
+
+	reset()
+	l.Error("How now brown cow?", gslog.LogNameKey, "audit")
+	assert.Nil(t, app.LogEntry.Payload)
+	assert.NotNil(t, audit.LogEntry.Payload)
+	assert.Nil(t, alerts.LogEntry.Payload)
+	assert.NotContains(t, audit.LogEntry.Payload.(*structpb.Struct).GetFields(), gslog.LogNameKey)
+
+	reset()
+	l.WithGroup("access").Info("How now brown cow?", "status", 200)
+	assert.Nil(t, app.LogEntry.Payload)
+	assert.NotNil(t, access.LogEntry.Payload)
+
+	reset()
+	l.Error("How now brown cow?")
+	assert.Nil(t, app.LogEntry.Payload)
+	assert.NotNil(t, alerts.LogEntry.Payload)
+
+	reset()
+	l.Info("How now brown cow?", gslog.LogNameKey, "unknown")
+	assert.NotNil(t, app.LogEntry.Payload)
+	assert.NotContains(t, app.LogEntry.Payload.(*structpb.Struct).GetFields(), gslog.LogNameKey)
+}
+
+func TestWithRoute_nil(t *testing.T) {
+	assert.PanicsWithValue(t, "matcher is nil", func() {
+		gslog.WithRoute(nil, Discard)
+	})
+	assert.PanicsWithValue(t, "logger is nil", func() {
+		gslog.WithRoute(gslog.RouteByGroup("g"), nil)
+	})
+}
+
+func TestGcpHandler_Flush_routes(t *testing.T) {
+	app := &flushCounter{}
+	audit := &flushCounter{err: errors.New("ouch")}
+
+	h := gslog.NewGcpHandler(app, gslog.WithRoute(gslog.RouteByLogName("audit"), audit))
+
+	err := h.Flush()
+	assert.Error(t, err)
+	assert.Equal(t, 1, app.flushes)
+	assert.Equal(t, 1, audit.flushes)
+}
+
+func TestRouteBySeverity(t *testing.T) {
+	m := gslog.RouteBySeverity(slog.LevelWarn)
+
+	assert.False(t, m(context.Background(), &logging.Entry{Severity: logging.Info}, nil))
+	assert.True(t, m(context.Background(), &logging.Entry{Severity: logging.Warning}, nil))
+	assert.True(t, m(context.Background(), &logging.Entry{Severity: logging.Error}, nil))
+}
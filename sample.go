@@ -0,0 +1,199 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"m4o.io/gslog/internal/options"
+)
+
+const (
+	// DroppedKey is the key of the field added to the payload of sampled
+	// entries that holds the number of similar records dropped since the
+	// last entry that was logged.
+	DroppedKey = "gslog/dropped"
+
+	sampleBuckets = 4096
+)
+
+// Sampling configures the sampling of records, see WithSampling.  Records are
+// considered similar when they share the same level and message, i.e. message
+// template.
+type Sampling struct {
+	// Rates maps levels to the fraction, between 0 and 1, of similar records
+	// that are logged.  A record is sampled using the rate of the greatest
+	// level that is less than or equal to its own.  Records whose level is
+	// below all the levels in Rates are all logged.
+	Rates map[slog.Level]float64
+
+	// Interval is the period over which First and Thereafter apply.  If
+	// zero, or First is zero, records are only sampled by Rates.
+	Interval time.Duration
+
+	// First is the number of similar records logged in each Interval before
+	// Thereafter applies.
+	First int
+
+	// Thereafter is M in "1 in M" similar records logged, in each Interval,
+	// once First have been logged.  If zero, or less, none are.
+	Thereafter int
+}
+
+// WithSampling returns an option that samples the records logged, to limit
+// the volume of high frequency records.  Records of LevelCritical, or higher,
+// are always logged.  Logged entries that follow dropped similar records
+// carry a DroppedKey field with the number of records that were dropped,
+// so that the counts can be reconstructed.
+//
+// For example, the following logs a tenth of the DEBUG records and, for INFO
+// and above, the first 100 similar records per second, then 1 in 10.
+//
+//	gslog.WithSampling(gslog.Sampling{
+//		Rates:      map[slog.Level]float64{slog.LevelDebug: 0.1, slog.LevelInfo: 1},
+//		Interval:   time.Second,
+//		First:      100,
+//		Thereafter: 10,
+//	})
+func WithSampling(sampling Sampling) options.OptionProcessor {
+	s := newSampler(sampling)
+
+	return func(o *options.Options) {
+		o.Sample = s.sample
+	}
+}
+
+// sampler implements the sampling of records.  Similar records are tracked
+// in a fixed number of buckets, bounding the memory used, at the expense of
+// occasionally sharing a bucket between records that are not similar.
+type sampler struct {
+	levels     []slog.Level
+	rates      []float64
+	interval   time.Duration
+	first      uint64
+	thereafter uint64
+
+	mu      sync.Mutex
+	buckets [sampleBuckets]sampleBucket
+}
+
+type sampleBucket struct {
+	windowStart time.Time
+	inWindow    uint64
+	seen        uint64
+	dropped     uint64
+}
+
+func newSampler(sampling Sampling) *sampler {
+	//nolint:exhaustruct
+	s := &sampler{
+		interval: sampling.Interval,
+	}
+
+	if sampling.First > 0 {
+		s.first = uint64(sampling.First)
+	}
+
+	if sampling.Thereafter > 0 {
+		s.thereafter = uint64(sampling.Thereafter)
+	}
+
+	for l := range sampling.Rates {
+		s.levels = append(s.levels, l)
+	}
+
+	slices.Sort(s.levels)
+
+	for _, l := range s.levels {
+		s.rates = append(s.rates, math.Max(0, math.Min(1, sampling.Rates[l])))
+	}
+
+	return s
+}
+
+func (s *sampler) sample(r slog.Record) (bool, uint64) {
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := &s.buckets[bucketOf(r.Level, r.Message)]
+
+	if r.Level < LevelCritical && (!s.keepByRate(b, r.Level) || !s.keepByInterval(b, now)) {
+		b.dropped++
+
+		return false, 0
+	}
+
+	dropped := b.dropped
+	b.dropped = 0
+
+	return true, dropped
+}
+
+// keepByRate reports whether the record is logged according to the rate of
+// its level.  Rather than at random, records are logged at regular intervals,
+// whenever the running count multiplied by the rate reaches a new integer.
+func (s *sampler) keepByRate(b *sampleBucket, level slog.Level) bool {
+	i, found := slices.BinarySearch(s.levels, level)
+	if !found {
+		i--
+	}
+
+	if i < 0 {
+		return true
+	}
+
+	rate := s.rates[i]
+
+	b.seen++
+
+	return math.Floor(float64(b.seen)*rate) > math.Floor(float64(b.seen-1)*rate)
+}
+
+func (s *sampler) keepByInterval(b *sampleBucket, now time.Time) bool {
+	if s.interval <= 0 || s.first == 0 {
+		return true
+	}
+
+	if now.Sub(b.windowStart) >= s.interval || now.Before(b.windowStart) {
+		b.windowStart = now
+		b.inWindow = 0
+	}
+
+	b.inWindow++
+
+	if b.inWindow <= s.first {
+		return true
+	}
+
+	return s.thereafter > 0 && (b.inWindow-s.first)%s.thereafter == 0
+}
+
+func bucketOf(level slog.Level, msg string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte{byte(level)})
+	_, _ = h.Write([]byte(msg))
+
+	return h.Sum64() % sampleBuckets
+}
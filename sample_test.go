@@ -0,0 +1,112 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog"
+)
+
+func handleAt(t *testing.T, h slog.Handler, at time.Time, level slog.Level, msg string) {
+	t.Helper()
+
+	r := slog.NewRecord(at, level, msg, 0)
+	assert.NoError(t, h.Handle(context.Background(), r))
+}
+
+func dropped(c *collector, i int) float64 {
+	return c.entries[i].Payload.(*structpb.Struct).GetFields()[gslog.DroppedKey].GetNumberValue()
+}
+
+func TestSampling_rates(t *testing.T) {
+	c := &collector{}
+	h := gslog.NewGcpHandler(c,
+		gslog.WithLogLeveler(slog.LevelDebug),
+		gslog.WithSampling(gslog.Sampling{
+			Rates: map[slog.Level]float64{slog.LevelDebug: 0.25, slog.LevelInfo: 1},
+		}))
+
+	for i := 0; i < 8; i++ {
+		handleAt(t, h, testTime, slog.LevelDebug, "debug")
+		handleAt(t, h, testTime, slog.LevelInfo, "info")
+	}
+
+	var debugs, infos int
+	for _, e := range c.entries {
+		switch e.Payload.(*structpb.Struct).GetFields()[gslog.MessageKey].GetStringValue() {
+		case "debug":
+			debugs++
+		case "info":
+			infos++
+		}
+	}
+
+	assert.Equal(t, 2, debugs)
+	assert.Equal(t, 8, infos)
+
+	// the first debug record logged, after three infos, follows 3 dropped ones
+	assert.Equal(t, float64(3), dropped(c, 3))
+}
+
+func TestSampling_firstThenThereafter(t *testing.T) {
+	c := &collector{}
+	h := gslog.NewGcpHandler(c, gslog.WithSampling(gslog.Sampling{
+		Interval:   time.Second,
+		First:      2,
+		Thereafter: 3,
+	}))
+
+	for i := 0; i < 8; i++ {
+		handleAt(t, h, testTime, slog.LevelInfo, "How now brown cow?")
+	}
+
+	// records 1, 2, 5 and 8 are logged
+	assert.Len(t, c.entries, 4)
+	assert.Equal(t, float64(0), dropped(c, 1))
+	assert.Equal(t, float64(2), dropped(c, 2))
+	assert.Equal(t, float64(2), dropped(c, 3))
+
+	// a different message is sampled independently
+	handleAt(t, h, testTime, slog.LevelInfo, "The rain in Spain lies mainly on the plane.")
+	assert.Len(t, c.entries, 5)
+
+	// a new interval starts over
+	handleAt(t, h, testTime.Add(time.Second), slog.LevelInfo, "How now brown cow?")
+	assert.Len(t, c.entries, 6)
+}
+
+func TestSampling_criticalAlwaysLogged(t *testing.T) {
+	c := &collector{}
+	h := gslog.NewGcpHandler(c, gslog.WithSampling(gslog.Sampling{
+		Rates: map[slog.Level]float64{slog.LevelDebug: 0},
+	}))
+
+	for _, level := range []slog.Level{gslog.LevelCritical, gslog.LevelAlert, gslog.LevelEmergency} {
+		for i := 0; i < 3; i++ {
+			handleAt(t, h, testTime, level, "Danger, Will Robinson!")
+		}
+	}
+
+	handleAt(t, h, testTime, slog.LevelError, "Ouch!")
+
+	assert.Len(t, c.entries, 9)
+}
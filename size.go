@@ -0,0 +1,422 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"cloud.google.com/go/logging"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/proto"
+	spb "google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog/internal/attr"
+	"m4o.io/gslog/internal/options"
+)
+
+const (
+	// MaxEntrySize is the maximum size, in bytes, of an entry accepted by
+	// Cloud Logging.
+	MaxEntrySize = 256 * 1024
+
+	// DefaultSizeLimit is the size limit of an entry's payload and labels
+	// used by WithSizeLimit when none is given.  It leaves room for the
+	// entry's other fields, e.g. its trace, source location and resource.
+	DefaultSizeLimit = MaxEntrySize - 8*1024
+
+	// TruncatedKey is the key of the marker added to the payload of entries
+	// that were reduced to fit within the size limit.
+	TruncatedKey = "gslog/truncated"
+
+	// SplitKey is the key of the marker added to the payload of entries
+	// that are parts of a split message.
+	SplitKey = "gslog/split"
+
+	minStringSize = 64
+	ellipsis      = "…"
+	pathSeparator = "."
+)
+
+// TruncationStrategy identifies how entries exceeding the size limit set via
+// WithSizeLimit are reduced.
+type TruncationStrategy options.TruncationStrategy
+
+const (
+	// TruncateLargestStrings repeatedly truncates the largest string field
+	// of the payload until the entry fits.
+	TruncateLargestStrings TruncationStrategy = iota
+	// DropDeepestGroups repeatedly removes the most deeply nested group of
+	// the payload until the entry fits.
+	DropDeepestGroups
+	// SplitMessage splits the message across as many entries as needed for
+	// each one to fit, every part carrying all the other fields.
+	SplitMessage
+)
+
+// String returns the name of the strategy.
+func (s TruncationStrategy) String() string {
+	switch s {
+	case TruncateLargestStrings:
+		return "truncate_largest_strings"
+	case DropDeepestGroups:
+		return "drop_deepest_groups"
+	case SplitMessage:
+		return "split_message"
+	default:
+		return "unknown(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// WithSizeLimit returns an option that enforces a limit on the size, in
+// bytes, of an entry's payload and labels, reducing the entries that exceed it
+// using the supplied strategy.  A limit of zero, or less, uses
+// DefaultSizeLimit.
+//
+// Reduced entries are marked with a TruncatedKey field that records the
+// strategy, the original size and the paths of the affected fields.  If the
+// strategy cannot make the entry fit, the other strategies are tried in turn.
+func WithSizeLimit(limit int, strategy TruncationStrategy) options.OptionProcessor {
+	if limit <= 0 {
+		limit = DefaultSizeLimit
+	}
+
+	return func(o *options.Options) {
+		o.SizeLimit = limit
+		o.TruncationStrategy = options.TruncationStrategy(strategy)
+	}
+}
+
+// sizeLimiter reduces entries whose payload and labels exceed a limit.
+type sizeLimiter struct {
+	limit    int
+	strategy TruncationStrategy
+}
+
+// enforce returns the entries to be logged in place of e, which are e itself
+// if it fits within the limit.
+func (l *sizeLimiter) enforce(e logging.Entry) []logging.Entry {
+	payload, ok := e.Payload.(*spb.Struct)
+	if !ok {
+		return []logging.Entry{e}
+	}
+
+	size := entrySize(payload, e.Labels)
+	if size <= l.limit {
+		return []logging.Entry{e}
+	}
+
+	if l.strategy == SplitMessage {
+		if parts := l.split(payload, e.Labels); parts != nil {
+			entries := make([]logging.Entry, len(parts))
+
+			for i, p := range parts {
+				entries[i] = e
+				entries[i].Payload = p
+
+				// each part is an entry of its own: the operation's first
+				// entry is the first part and its last entry the last part,
+				// and the parts must not be dropped as duplicates
+				if e.Operation != nil {
+					//nolint:forcetypeassert
+					op := proto.Clone(e.Operation).(*logpb.LogEntryOperation)
+					op.First = e.Operation.GetFirst() && i == 0
+					op.Last = e.Operation.GetLast() && i == len(parts)-1
+					entries[i].Operation = op
+				}
+
+				if e.InsertID != "" {
+					entries[i].InsertID = e.InsertID + "-" + strconv.Itoa(i)
+				}
+			}
+
+			return entries
+		}
+	}
+
+	// the payload shares values with the handler, so a copy is reduced
+	//nolint:forcetypeassert
+	payload = proto.Clone(payload).(*spb.Struct)
+	e.Payload = payload
+
+	var paths []string
+
+	budget := l.limit - labelsSize(e.Labels)
+
+	// The marker counts towards the size, so it is added upfront and updated
+	// as the affected fields become known.
+	markTruncated(payload, l.strategy, size, nil)
+
+	for _, reduce := range l.reducers() {
+		for {
+			reduced := reduce(payload, budget)
+			if len(reduced) == 0 {
+				break
+			}
+
+			for _, r := range reduced {
+				if !slices.Contains(paths, r) {
+					paths = append(paths, r)
+				}
+			}
+
+			markTruncated(payload, l.strategy, size, paths)
+		}
+
+		if proto.Size(payload) <= budget {
+			break
+		}
+	}
+
+	return []logging.Entry{e}
+}
+
+// reducers returns the payload reducers to apply, the configured strategy's
+// first.
+func (l *sizeLimiter) reducers() []func(*spb.Struct, int) []string {
+	if l.strategy == DropDeepestGroups {
+		return []func(*spb.Struct, int) []string{dropDeepestGroups, truncateLargestStrings}
+	}
+
+	return []func(*spb.Struct, int) []string{truncateLargestStrings, dropDeepestGroups}
+}
+
+// split splits the message of the payload into parts that each fit within
+// the limit.  Nil is returned if the message cannot be split.
+func (l *sizeLimiter) split(payload *spb.Struct, labels map[string]string) []*spb.Struct {
+	msg, ok := payload.GetFields()[MessageKey].GetKind().(*spb.Value_StringValue)
+	if !ok {
+		return nil
+	}
+
+	message := msg.StringValue
+
+	//nolint:forcetypeassert
+	base := proto.Clone(payload).(*spb.Struct)
+	delete(base.GetFields(), MessageKey)
+
+	// account for the marker, the message key and the proto overhead
+	const overhead = 128
+
+	chunkSize := l.limit - entrySize(base, labels) - overhead
+	if chunkSize < minStringSize {
+		return nil
+	}
+
+	var chunks []string
+
+	for len(message) > 0 {
+		n := truncationPoint(message, chunkSize)
+		chunks = append(chunks, message[:n])
+		message = message[n:]
+	}
+
+	uid := newSplitUID()
+	parts := make([]*spb.Struct, len(chunks))
+
+	for i, c := range chunks {
+		//nolint:forcetypeassert
+		p := proto.Clone(base).(*spb.Struct)
+		if p.Fields == nil {
+			// cloning a struct without fields leaves its map nil
+			p.Fields = make(map[string]*spb.Value, 2)
+		}
+
+		p.Fields[MessageKey] = attr.NewStringValue(c)
+		p.Fields[SplitKey] = &spb.Value{Kind: &spb.Value_StructValue{StructValue: &spb.Struct{
+			Fields: map[string]*spb.Value{
+				"uid":         attr.NewStringValue(uid),
+				"index":       attr.NewNumberValue(float64(i)),
+				"totalSplits": attr.NewNumberValue(float64(len(chunks))),
+			},
+		}}}
+		parts[i] = p
+	}
+
+	return parts
+}
+
+// truncateLargestStrings truncates the largest string fields of the payload
+// until it fits within budget, or no string can be truncated further.
+func truncateLargestStrings(payload *spb.Struct, budget int) []string {
+	var paths []string
+
+	for {
+		excess := proto.Size(payload) - budget
+		if excess <= 0 {
+			return paths
+		}
+
+		path, v := largestString(payload, "")
+		if v == nil {
+			return paths
+		}
+
+		s := v.GetStringValue()
+
+		keep := len(s) - excess - len(ellipsis)
+		if keep < minStringSize {
+			keep = minStringSize
+		}
+
+		v.Kind = &spb.Value_StringValue{StringValue: s[:truncationPoint(s, keep)] + ellipsis}
+
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+}
+
+// dropDeepestGroups removes the most deeply nested groups of the payload
+// until it fits within budget, or no groups are left.
+func dropDeepestGroups(payload *spb.Struct, budget int) []string {
+	var paths []string
+
+	for proto.Size(payload) > budget {
+		parent, key, path, _ := deepestGroup(payload, "", 0)
+		if parent == nil {
+			break
+		}
+
+		delete(parent.GetFields(), key)
+
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// largestString returns the largest string value, longer than minStringSize,
+// found in the payload, along with its path.
+func largestString(s *spb.Struct, prefix string) (string, *spb.Value) {
+	var largestPath string
+
+	var largest *spb.Value
+
+	consider := func(path string, v *spb.Value) {
+		if len(v.GetStringValue()) > minStringSize+len(ellipsis) &&
+			(largest == nil || len(v.GetStringValue()) > len(largest.GetStringValue())) {
+			largestPath, largest = path, v
+		}
+	}
+
+	var walk func(path string, v *spb.Value)
+
+	walk = func(path string, v *spb.Value) {
+		switch k := v.GetKind().(type) {
+		case *spb.Value_StringValue:
+			consider(path, v)
+		case *spb.Value_StructValue:
+			if p, sv := largestString(k.StructValue, path+pathSeparator); sv != nil {
+				consider(p, sv)
+			}
+		case *spb.Value_ListValue:
+			for i, lv := range k.ListValue.GetValues() {
+				walk(path+"["+strconv.Itoa(i)+"]", lv)
+			}
+		}
+	}
+
+	for key, v := range s.GetFields() {
+		if key == TruncatedKey || key == SplitKey {
+			continue
+		}
+
+		walk(prefix+key, v)
+	}
+
+	return largestPath, largest
+}
+
+// deepestGroup returns the most deeply nested group found in the payload,
+// as the struct holding it and its key, along with its path and depth.
+func deepestGroup(s *spb.Struct, prefix string, depth int) (*spb.Struct, string, string, int) {
+	var parent *spb.Struct
+
+	var key, path string
+
+	deepest := -1
+
+	for k, v := range s.GetFields() {
+		sv := v.GetStructValue()
+		if sv == nil || k == TruncatedKey || k == SplitKey {
+			continue
+		}
+
+		p, pk, pp, d := deepestGroup(sv, prefix+k+pathSeparator, depth+1)
+		if p == nil {
+			p, pk, pp, d = s, k, prefix+k, depth
+		}
+
+		if d > deepest || (d == deepest && pp < path) {
+			parent, key, path, deepest = p, pk, pp, d
+		}
+	}
+
+	return parent, key, path, deepest
+}
+
+func markTruncated(payload *spb.Struct, strategy TruncationStrategy, size int, paths []string) {
+	values := make([]*spb.Value, len(paths))
+	for i, p := range paths {
+		values[i] = attr.NewStringValue(p)
+	}
+
+	payload.Fields[TruncatedKey] = &spb.Value{Kind: &spb.Value_StructValue{StructValue: &spb.Struct{
+		Fields: map[string]*spb.Value{
+			"strategy":     attr.NewStringValue(strategy.String()),
+			"originalSize": attr.NewNumberValue(float64(size)),
+			"fields":       {Kind: &spb.Value_ListValue{ListValue: &spb.ListValue{Values: values}}},
+		},
+	}}}
+}
+
+func entrySize(payload *spb.Struct, labels map[string]string) int {
+	return proto.Size(payload) + labelsSize(labels)
+}
+
+func labelsSize(labels map[string]string) int {
+	size := 0
+	for k, v := range labels {
+		size += len(k) + len(v)
+	}
+
+	return size
+}
+
+// truncationPoint returns the largest index, no greater than n, at which s
+// can be cut without splitting a UTF-8 encoded rune.
+func truncationPoint(s string, n int) int {
+	if n >= len(s) {
+		return len(s)
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return n
+}
+
+func newSplitUID() string {
+	var b [8]byte
+
+	_, _ = rand.Read(b[:])
+
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"m4o.io/gslog"
+)
+
+type collector struct {
+	entries []logging.Entry
+}
+
+func (c *collector) Log(e logging.Entry) {
+	c.entries = append(c.entries, e)
+}
+
+func (c *collector) LogSync(_ context.Context, e logging.Entry) error {
+	c.entries = append(c.entries, e)
+	return nil
+}
+
+func (c *collector) Flush() error {
+	return nil
+}
+
+const sizeLimit = 4 * 1024
+
+func TestSizeLimit_fits(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithSizeLimit(sizeLimit, gslog.TruncateLargestStrings)))
+
+	l.Info("How now brown cow?", "body", strings.Repeat("a", 1024))
+
+	assert.Len(t, c.entries, 1)
+	assert.NotContains(t, c.entries[0].Payload.(*structpb.Struct).GetFields(), gslog.TruncatedKey)
+}
+
+func TestSizeLimit_truncateLargestStrings(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithSizeLimit(sizeLimit, gslog.TruncateLargestStrings)))
+
+	l.Info("How now brown cow?",
+		"small", strings.Repeat("s", 512),
+		slog.Group("response", "body", strings.Repeat("b", 16*1024)))
+
+	assert.Len(t, c.entries, 1)
+
+	payload := c.entries[0].Payload.(*structpb.Struct)
+	fields := payload.GetFields()
+
+	assert.LessOrEqual(t, proto.Size(payload), sizeLimit)
+	assert.Equal(t, "How now brown cow?", fields[gslog.MessageKey].GetStringValue())
+	assert.Equal(t, strings.Repeat("s", 512), fields["small"].GetStringValue())
+
+	body := fields["response"].GetStructValue().GetFields()["body"].GetStringValue()
+	assert.True(t, strings.HasSuffix(body, "…"))
+	assert.Less(t, len(body), 16*1024)
+
+	marker := fields[gslog.TruncatedKey].GetStructValue().GetFields()
+	assert.Equal(t, "truncate_largest_strings", marker["strategy"].GetStringValue())
+	assert.Greater(t, marker["originalSize"].GetNumberValue(), float64(16*1024))
+	assert.Equal(t, "response.body", marker["fields"].GetListValue().GetValues()[0].GetStringValue())
+}
+
+func TestSizeLimit_dropDeepestGroups(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithSizeLimit(sizeLimit, gslog.DropDeepestGroups)))
+
+	l.Info("How now brown cow?",
+		"a", 1,
+		slog.Group("g",
+			"b", 2,
+			slog.Group("h", "body", strings.Repeat("b", 16*1024))))
+
+	assert.Len(t, c.entries, 1)
+
+	payload := c.entries[0].Payload.(*structpb.Struct)
+	fields := payload.GetFields()
+
+	assert.LessOrEqual(t, proto.Size(payload), sizeLimit)
+	assert.Contains(t, fields, "a")
+	assert.Contains(t, fields["g"].GetStructValue().GetFields(), "b")
+	assert.NotContains(t, fields["g"].GetStructValue().GetFields(), "h")
+
+	marker := fields[gslog.TruncatedKey].GetStructValue().GetFields()
+	assert.Equal(t, "drop_deepest_groups", marker["strategy"].GetStringValue())
+	assert.Equal(t, "g.h", marker["fields"].GetListValue().GetValues()[0].GetStringValue())
+}
+
+func TestSizeLimit_splitMessage(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c, gslog.WithSizeLimit(sizeLimit, gslog.SplitMessage)))
+
+	msg := strings.Repeat("How now brown cow? ", 1000)
+
+	l.Info(msg, "a", 1)
+
+	assert.Greater(t, len(c.entries), 1)
+
+	var sb strings.Builder
+
+	var uid string
+
+	for i, e := range c.entries {
+		payload := e.Payload.(*structpb.Struct)
+		fields := payload.GetFields()
+
+		assert.LessOrEqual(t, proto.Size(payload), sizeLimit)
+		assert.Equal(t, float64(1), fields["a"].GetNumberValue())
+
+		split := fields[gslog.SplitKey].GetStructValue().GetFields()
+		assert.Equal(t, float64(i), split["index"].GetNumberValue())
+		assert.Equal(t, float64(len(c.entries)), split["totalSplits"].GetNumberValue())
+
+		if i == 0 {
+			uid = split["uid"].GetStringValue()
+		}
+		assert.Equal(t, uid, split["uid"].GetStringValue())
+
+		sb.WriteString(fields[gslog.MessageKey].GetStringValue())
+	}
+
+	assert.Equal(t, msg, sb.String())
+}
+
+func TestTruncationStrategy_String(t *testing.T) {
+	assert.Equal(t, "split_message", gslog.SplitMessage.String())
+	assert.Equal(t, "unknown(42)", gslog.TruncationStrategy(42).String())
+}
+
+func TestSizeLimit_splitMessage_operation(t *testing.T) {
+	c := &collector{}
+	l := slog.New(gslog.NewGcpHandler(c,
+		gslog.WithSizeLimit(sizeLimit, gslog.SplitMessage),
+		gslog.WithEntryAugmentor("insert-id", func(_ context.Context, e *logging.Entry, _ []string) error {
+			e.InsertID = "id"
+			return nil
+		})))
+
+	// the only entry of the operation is both its first and last
+	ctx := gslog.EndOperation(gslog.WithOperation(context.Background(), "op-1", "test"))
+	l.InfoContext(ctx, strings.Repeat("How now brown cow? ", 1000))
+
+	n := len(c.entries)
+	assert.Greater(t, n, 2)
+
+	for i, e := range c.entries {
+		assert.Equal(t, "op-1", e.Operation.GetId())
+		assert.Equal(t, i == 0, e.Operation.GetFirst(), i)
+		assert.Equal(t, i == n-1, e.Operation.GetLast(), i)
+		assert.Equal(t, "id-"+strconv.Itoa(i), e.InsertID)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog
+
+import (
+	"context"
+	"log/slog"
+
+	"m4o.io/gslog/internal/options"
+)
+
+// SyncPolicy reports whether the entry for a slog.Record is to be logged
+// synchronously, via LogSync, rather than buffered, via Log.
+type SyncPolicy options.SyncPolicy
+
+// WithSyncPolicy returns an option that specifies the SyncPolicy that decides
+// which records are logged synchronously.  By default, records of
+// LevelCritical, or higher, are.
+func WithSyncPolicy(policy SyncPolicy) options.OptionProcessor {
+	if policy == nil {
+		panic("sync policy is nil")
+	}
+
+	return func(o *options.Options) {
+		o.SyncPolicy = options.SyncPolicy(policy)
+	}
+}
+
+// SyncAtLevel returns a SyncPolicy that logs records of the supplied level,
+// or higher, synchronously.
+func SyncAtLevel(level slog.Level) SyncPolicy {
+	return func(_ context.Context, r slog.Record) bool {
+		return r.Level >= level
+	}
+}
+
+// SyncNever returns a SyncPolicy that never logs records synchronously.
+func SyncNever() SyncPolicy {
+	return func(context.Context, slog.Record) bool {
+		return false
+	}
+}
+
+type syncKey struct{}
+
+// WithSync returns a new Context that overrides the handler's SyncPolicy for
+// the GCP log entries produced using that context.  For example, the final
+// record logged before calling os.Exit can be forced to be written
+// synchronously.
+func WithSync(ctx context.Context, sync bool) context.Context {
+	return context.WithValue(ctx, syncKey{}, sync)
+}
+
+// shouldSync reports whether the record is to be logged synchronously.
+func (h *GcpHandler) shouldSync(ctx context.Context, r slog.Record) bool {
+	if sync, ok := ctx.Value(syncKey{}).(bool); ok {
+		return sync
+	}
+
+	return h.syncPolicy(ctx, r)
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 The original author or authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gslog_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"m4o.io/gslog"
+	"m4o.io/gslog/internal/options"
+)
+
+func TestSyncPolicy(t *testing.T) {
+	background := context.Background()
+
+	for name, tc := range map[string]struct {
+		opts  []options.OptionProcessor
+		ctx   context.Context
+		level slog.Level
+		sync  bool
+	}{
+		"default info":         {nil, background, slog.LevelInfo, false},
+		"default error":        {nil, background, slog.LevelError, false},
+		"default critical":     {nil, background, gslog.LevelCritical, true},
+		"level below":          {[]options.OptionProcessor{gslog.WithSyncPolicy(gslog.SyncAtLevel(slog.LevelError))}, background, slog.LevelWarn, false},
+		"level at":             {[]options.OptionProcessor{gslog.WithSyncPolicy(gslog.SyncAtLevel(slog.LevelError))}, background, slog.LevelError, true},
+		"never":                {[]options.OptionProcessor{gslog.WithSyncPolicy(gslog.SyncNever())}, background, gslog.LevelEmergency, false},
+		"context forces sync":  {[]options.OptionProcessor{gslog.WithSyncPolicy(gslog.SyncNever())}, gslog.WithSync(background, true), slog.LevelInfo, true},
+		"context forces async": {nil, gslog.WithSync(background, false), gslog.LevelCritical, false},
+		"predicate on record": {[]options.OptionProcessor{gslog.WithSyncPolicy(func(_ context.Context, r slog.Record) bool {
+			return r.Message == "sync me"
+		})}, background, slog.LevelDebug - 4, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := &Got{}
+			opts := append([]options.OptionProcessor{gslog.WithLogLeveler(slog.LevelDebug - 4)}, tc.opts...)
+			l := slog.New(gslog.NewGcpHandler(got, opts...))
+
+			l.Log(tc.ctx, tc.level, "sync me")
+
+			if tc.sync {
+				assert.NotNil(t, got.SyncLogEntry.Payload)
+				assert.Nil(t, got.LogEntry.Payload)
+			} else {
+				assert.NotNil(t, got.LogEntry.Payload)
+				assert.Nil(t, got.SyncLogEntry.Payload)
+			}
+		})
+	}
+}
+
+func TestWithSyncPolicy_nil(t *testing.T) {
+	assert.PanicsWithValue(t, "sync policy is nil", func() {
+		gslog.WithSyncPolicy(nil)
+	})
+}